@@ -0,0 +1,65 @@
+package fixtures
+
+import (
+	"fmt"
+)
+
+// tableDefaults holds the default fields of tables, by table name as written
+// in fixture files
+type tableDefaults map[string]map[string]interface{}
+
+// merge adds defaults, overriding fields that are already set
+func (d tableDefaults) merge(defaults tableDefaults) {
+	for table, fields := range defaults {
+		if d[table] == nil {
+			d[table] = make(map[string]interface{})
+		}
+		for column, value := range fields {
+			d[table][column] = value
+		}
+	}
+}
+
+// inheritFields merges into the fields of a row the defaults of its table and
+// the fields of the labelled row it extends, in that order, with the row's own
+// fields taking precedence
+func inheritFields(row *Row, defaults []tableDefaults, all ...labels) error {
+	name := row.Table
+	if row.Schema != "" {
+		name = row.Schema + "." + row.Table
+	}
+	_, table := splitTable(name)
+
+	fields := make(map[string]interface{})
+	for _, d := range defaults {
+		for _, key := range []string{table, name} {
+			for column, value := range d[key] {
+				fields[column] = value
+			}
+		}
+	}
+
+	if row.Extends != "" {
+		var base *labelledRow
+		for _, l := range all {
+			if base = l[name+"."+row.Extends]; base != nil {
+				break
+			}
+		}
+		if base == nil {
+			return fmt.Errorf(
+				"Unknown base row %s.%s: no %s row labelled %s has been loaded before it",
+				name, row.Extends, name, row.Extends,
+			)
+		}
+		for column, value := range base.fields {
+			fields[column] = value
+		}
+	}
+
+	for column, value := range row.Fields {
+		fields[column] = value
+	}
+	row.Fields = fields
+	return nil
+}
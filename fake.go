@@ -0,0 +1,290 @@
+package fixtures
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// defaultLocale is the locale of FAKE() values unless FakeLocale is set
+const defaultLocale = "en"
+
+// Locale holds the data FAKE() values are drawn from. In the Postcode, Phone
+// and IBANFormat formats every # is replaced by a random digit and every ?
+// by a random upper case letter. Address is the format of addresses, with
+// {number}, {street}, {postcode} and {city} replaced.
+type Locale struct {
+	FirstNames  []string
+	LastNames   []string
+	Streets     []string
+	Cities      []string
+	Domains     []string
+	Words       []string
+	Postcode    string
+	Phone       string
+	Address     string
+	IBANCountry string
+	IBANFormat  string
+}
+
+// loremWords are the words of lorem text in every built-in locale
+var loremWords = []string{
+	"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing",
+	"elit", "sed", "do", "eiusmod", "tempor", "incididunt", "ut", "labore",
+	"et", "dolore", "magna", "aliqua", "enim", "ad", "minim", "veniam",
+	"quis", "nostrud", "exercitation", "ullamco", "laboris", "nisi",
+	"aliquip", "ex", "ea", "commodo", "consequat", "duis", "aute", "irure",
+	"in", "reprehenderit", "voluptate", "velit", "esse", "cillum", "fugiat",
+	"nulla", "pariatur", "excepteur", "sint", "occaecat", "cupidatat", "non",
+	"proident", "sunt", "culpa", "qui", "officia", "deserunt", "mollit",
+	"anim", "id", "est", "laborum",
+}
+
+var (
+	localesMu sync.RWMutex
+	locales   = map[string]*Locale{
+		"en": {
+			FirstNames:  []string{"James", "Mary", "John", "Patricia", "Robert", "Jennifer", "Michael", "Linda", "William", "Elizabeth", "David", "Susan", "Richard", "Jessica", "Thomas", "Sarah"},
+			LastNames:   []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Miller", "Davis", "Wilson", "Anderson", "Taylor", "Moore", "Jackson", "Martin", "Lee", "Thompson", "White"},
+			Streets:     []string{"Main Street", "Oak Avenue", "Maple Drive", "Park Road", "Cedar Lane", "Elm Street", "Washington Avenue", "Lake View Road", "Hill Street", "Church Lane"},
+			Cities:      []string{"Springfield", "Riverside", "Franklin", "Greenville", "Bristol", "Clinton", "Fairview", "Salem", "Madison", "Georgetown"},
+			Domains:     []string{"example.com", "example.org", "example.net"},
+			Words:       loremWords,
+			Postcode:    "#####",
+			Phone:       "+1 ###-###-####",
+			Address:     "{number} {street}, {city} {postcode}",
+			IBANCountry: "GB",
+			IBANFormat:  "????##############",
+		},
+		"de": {
+			FirstNames:  []string{"Lukas", "Anna", "Jonas", "Lea", "Maximilian", "Sophie", "Felix", "Marie", "Paul", "Hannah", "Jürgen", "Katharina", "Stefan", "Julia", "Tobias", "Laura"},
+			LastNames:   []string{"Müller", "Schmidt", "Schneider", "Fischer", "Weber", "Meyer", "Wagner", "Becker", "Schulz", "Hoffmann", "Schäfer", "Koch", "Bauer", "Richter", "Klein", "Wolf"},
+			Streets:     []string{"Hauptstraße", "Schulstraße", "Gartenstraße", "Bahnhofstraße", "Dorfstraße", "Bergstraße", "Lindenstraße", "Kirchweg", "Waldweg", "Am Markt"},
+			Cities:      []string{"Berlin", "Hamburg", "München", "Köln", "Frankfurt", "Stuttgart", "Düsseldorf", "Leipzig", "Dresden", "Bremen"},
+			Domains:     []string{"example.de", "example.com", "example.org"},
+			Words:       loremWords,
+			Postcode:    "#####",
+			Phone:       "+49 ### #######",
+			Address:     "{street} {number}, {postcode} {city}",
+			IBANCountry: "DE",
+			IBANFormat:  "##################",
+		},
+	}
+
+	// fakeKinds are the values FAKE() can generate
+	fakeKinds = map[string]func(r *rand.Rand, l *Locale) string{
+		"first_name": func(r *rand.Rand, l *Locale) string { return pick(r, l.FirstNames) },
+		"last_name":  func(r *rand.Rand, l *Locale) string { return pick(r, l.LastNames) },
+		"name":       fakeName,
+		"email":      fakeEmail,
+		"phone":      func(r *rand.Rand, l *Locale) string { return fakeFormat(r, l.Phone) },
+		"street":     func(r *rand.Rand, l *Locale) string { return pick(r, l.Streets) },
+		"city":       func(r *rand.Rand, l *Locale) string { return pick(r, l.Cities) },
+		"postcode":   func(r *rand.Rand, l *Locale) string { return fakeFormat(r, l.Postcode) },
+		"address":    fakeAddress,
+		"iban":       fakeIBAN,
+		"word":       func(r *rand.Rand, l *Locale) string { return pick(r, l.Words) },
+		"sentence":   fakeSentence,
+		"paragraph":  fakeParagraph,
+	}
+)
+
+// RegisterLocale makes a locale available to FAKE() and FakeLocale under a
+// name, replacing any locale registered under the same name
+func RegisterLocale(name string, locale *Locale) {
+	localesMu.Lock()
+	defer localesMu.Unlock()
+	locales[name] = locale
+}
+
+// lookupLocale returns a registered locale
+func lookupLocale(name string) (*Locale, error) {
+	localesMu.RLock()
+	defer localesMu.RUnlock()
+	locale, ok := locales[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown locale %q", name)
+	}
+	return locale, nil
+}
+
+// valueSource holds the state value functions share during a load: the seed
+// of random numbers for FAKE() and RANDOM_INT() and the SEQ() counters
+type valueSource struct {
+	seed      int64
+	locale    string
+	rand      *rand.Rand
+	sequences map[string]int64
+}
+
+// newValueSource returns the value function state of a load. SEQ() counters
+// start at 0 unless LoadFiles or Use share them across their files.
+func newValueSource(o *options) *valueSource {
+	sequences := o.sequences
+	if sequences == nil {
+		sequences = make(map[string]int64)
+	}
+	return &valueSource{
+		seed:      o.fakeSeed,
+		locale:    o.fakeLocale,
+		rand:      rand.New(rand.NewSource(o.fakeSeed)),
+		sequences: sequences,
+	}
+}
+
+// columnRand returns the random numbers for a column of a row. Rows with a
+// primary key or match columns get numbers seeded by the table, key and
+// column, which don't depend on the other rows of the fixture. Rows without
+// one share a sequence seeded once per load.
+func (f *valueSource) columnRand(table string, key map[string]interface{}, column string) *rand.Rand {
+	if len(key) == 0 {
+		return f.rand
+	}
+
+	columns := make([]string, 0, len(key))
+	for c := range key {
+		columns = append(columns, c)
+	}
+	sort.Strings(columns)
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d\x00%s\x00%s", f.seed, table, column)
+	for _, c := range columns {
+		fmt.Fprintf(h, "\x00%s=%v", c, key[c])
+	}
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
+// callFake returns fake data of a kind, such as FAKE(email) or
+// FAKE(address, de)
+func callFake(ctx *ValueContext, args []string) (interface{}, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("FAKE expects a kind and an optional locale")
+	}
+	kind, ok := fakeKinds[args[0]]
+	if !ok {
+		return nil, fmt.Errorf("Unknown fake kind %q", args[0])
+	}
+
+	name := ctx.Locale
+	if len(args) == 2 {
+		name = args[1]
+	}
+	if name == "" {
+		name = defaultLocale
+	}
+	locale, err := lookupLocale(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return kind(ctx.Rand(), locale), nil
+}
+
+// pick returns a random item of a list
+func pick(r *rand.Rand, items []string) string {
+	if len(items) == 0 {
+		return ""
+	}
+	return items[r.Intn(len(items))]
+}
+
+// fakeFormat replaces every # of a format by a random digit and every ? by a
+// random upper case letter
+func fakeFormat(r *rand.Rand, format string) string {
+	var b bytes.Buffer
+	for _, c := range format {
+		switch c {
+		case '#':
+			b.WriteByte(byte('0' + r.Intn(10)))
+		case '?':
+			b.WriteByte(byte('A' + r.Intn(26)))
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// fakeName returns a first and last name
+func fakeName(r *rand.Rand, l *Locale) string {
+	return pick(r, l.FirstNames) + " " + pick(r, l.LastNames)
+}
+
+// fakeEmail returns an email address made of a first and last name
+func fakeEmail(r *rand.Rand, l *Locale) string {
+	first, last := pick(r, l.FirstNames), pick(r, l.LastNames)
+	return fmt.Sprintf("%s.%s%d@%s", emailPart(first), emailPart(last), r.Intn(100), pick(r, l.Domains))
+}
+
+// emailPart lower cases a name and spells out the letters that are not
+// allowed in the local part of email addresses
+func emailPart(s string) string {
+	replacer := strings.NewReplacer("ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss", "é", "e", "è", "e", "á", "a", "à", "a")
+	s = replacer.Replace(strings.ToLower(s))
+
+	var b bytes.Buffer
+	for _, c := range s {
+		if c <= unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c)) {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// fakeAddress returns a street address
+func fakeAddress(r *rand.Rand, l *Locale) string {
+	return strings.NewReplacer(
+		"{number}", fmt.Sprint(1+r.Intn(200)),
+		"{street}", pick(r, l.Streets),
+		"{postcode}", fakeFormat(r, l.Postcode),
+		"{city}", pick(r, l.Cities),
+	).Replace(l.Address)
+}
+
+// fakeIBAN returns an IBAN with valid check digits
+func fakeIBAN(r *rand.Rand, l *Locale) string {
+	bban := fakeFormat(r, l.IBANFormat)
+	return l.IBANCountry + ibanCheckDigits(l.IBANCountry, bban) + bban
+}
+
+// ibanCheckDigits computes the ISO 7064 MOD 97-10 check digits of an IBAN
+func ibanCheckDigits(country, bban string) string {
+	mod := 0
+	for _, c := range strings.ToUpper(bban + country + "00") {
+		switch {
+		case c >= '0' && c <= '9':
+			mod = (mod*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			mod = (mod*100 + int(c-'A') + 10) % 97
+		}
+	}
+	return fmt.Sprintf("%02d", 98-mod)
+}
+
+// fakeSentence returns a sentence of lorem text
+func fakeSentence(r *rand.Rand, l *Locale) string {
+	words := make([]string, 6+r.Intn(7))
+	for i := range words {
+		words[i] = pick(r, l.Words)
+	}
+	sentence := strings.Join(words, " ")
+	if sentence == "" {
+		return sentence
+	}
+	return strings.ToUpper(sentence[:1]) + sentence[1:] + "."
+}
+
+// fakeParagraph returns a paragraph of lorem text
+func fakeParagraph(r *rand.Rand, l *Locale) string {
+	sentences := make([]string, 3+r.Intn(3))
+	for i := range sentences {
+		sentences[i] = fakeSentence(r, l)
+	}
+	return strings.Join(sentences, " ")
+}
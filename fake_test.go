@@ -0,0 +1,83 @@
+package fixtures
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallFake(t *testing.T) {
+	ctx := func(seed int64) *ValueContext {
+		return &ValueContext{Locale: "en", source: newValueSource(newOptions([]Option{FakeSeed(seed)}))}
+	}
+
+	// The same seed gives the same values
+	for kind := range fakeKinds {
+		a, err := callFake(ctx(1), []string{kind})
+		assert.Nil(t, err)
+		b, err := callFake(ctx(1), []string{kind})
+		assert.Nil(t, err)
+		assert.Equal(t, a, b, kind)
+		assert.NotEmpty(t, a, kind)
+	}
+
+	email, err := callFake(ctx(1), []string{"email"})
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[a-z]+\.[a-z]+\d*@example\.(com|org|net)$`), email)
+
+	// Locales can be picked per value
+	phone, err := callFake(ctx(1), []string{"phone", "de"})
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^\+49 \d{3} \d{7}$`), phone)
+
+	_, err = callFake(ctx(1), []string{"unknown"})
+	assert.EqualError(t, err, `Unknown fake kind "unknown"`)
+	_, err = callFake(ctx(1), []string{"name", "xx"})
+	assert.EqualError(t, err, `Unknown locale "xx"`)
+	_, err = callFake(ctx(1), nil)
+	assert.EqualError(t, err, "FAKE expects a kind and an optional locale")
+}
+
+func TestFakeIBAN(t *testing.T) {
+	// Check digits of a known IBAN
+	assert.Equal(t, "89", ibanCheckDigits("DE", "370400440532013000"))
+	assert.Equal(t, "29", ibanCheckDigits("GB", "NWBK60161331926819"))
+
+	for _, locale := range []string{"en", "de"} {
+		iban, err := callFake(&ValueContext{source: newValueSource(newOptions([]Option{FakeSeed(7)}))}, []string{"iban", locale})
+		assert.Nil(t, err)
+		s := iban.(string)
+		assert.Equal(t, s[2:4], ibanCheckDigits(s[:2], s[4:]))
+	}
+}
+
+func TestValueSourceColumnRand(t *testing.T) {
+	f := newValueSource(newOptions([]Option{FakeSeed(42)}))
+	key := map[string]interface{}{"id": 1}
+
+	// Rows with a key get the same numbers however many values came before
+	a := f.columnRand("users", key, "email").Int63()
+	f.rand.Int63()
+	assert.Equal(t, a, f.columnRand("users", key, "email").Int63())
+
+	// Other columns, keys, tables and seeds get other numbers
+	assert.NotEqual(t, a, f.columnRand("users", key, "name").Int63())
+	assert.NotEqual(t, a, f.columnRand("users", map[string]interface{}{"id": 2}, "email").Int63())
+	assert.NotEqual(t, a, f.columnRand("admins", key, "email").Int63())
+	assert.NotEqual(t, a, newValueSource(newOptions(nil)).columnRand("users", key, "email").Int63())
+
+	// Rows without a key share the sequence of the load
+	assert.True(t, f.columnRand("users", nil, "email") == f.rand)
+}
+
+func TestRegisterLocale(t *testing.T) {
+	RegisterLocale("test", &Locale{
+		FirstNames: []string{"Zed"},
+		LastNames:  []string{"Zulu"},
+	})
+
+	name, err := callFake(&ValueContext{Locale: "test"}, []string{"name"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Zed Zulu", name)
+}
@@ -0,0 +1,94 @@
+package fixtures
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fillRequired adds a placeholder value to the insert of a row for every NOT
+// NULL column without a default the fixture omits, and adds them to the
+// filled columns of its table. The required columns of every table are cached
+// in required.
+func fillRequired(q queryer, driver string, row *Row, required map[string][]requiredColumn, filled map[string][]string) error {
+	columns, ok := required[row.tableName()]
+	if !ok {
+		var err error
+		if columns, err = requiredColumns(q, driver, row.tableName()); err != nil {
+			return err
+		}
+		required[row.tableName()] = columns
+	}
+
+	for _, column := range columns {
+		if _, ok := row.key()[column.name]; ok {
+			continue
+		}
+		if _, ok := row.Fields[column.name]; ok {
+			continue
+		}
+		row.addInsertValue(column.name, placeholderValue(column.dataType))
+		if !containsString(filled[row.tableName()], column.name) {
+			filled[row.tableName()] = append(filled[row.tableName()], column.name)
+		}
+	}
+	return nil
+}
+
+// logFilled logs the columns filled in every table by a load, once per table
+func logFilled(o *options, filled map[string][]string) {
+	tables := make([]string, 0, len(filled))
+	for table := range filled {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	for _, table := range tables {
+		columns := filled[table]
+		sort.Strings(columns)
+		o.fillLogf("Auto-filled required columns of %s, add them to the fixture: %s", table, strings.Join(columns, ", "))
+	}
+}
+
+// placeholderValue returns a value of a column type for a required column:
+// 0 for numbers, false for booleans, the Unix epoch for dates and times, an
+// empty object for JSON and an empty string for anything else
+func placeholderValue(dataType string) interface{} {
+	dataType = strings.ToLower(strings.TrimSpace(dataType))
+	if strings.HasSuffix(dataType, "[]") {
+		return "{}"
+	}
+	if i := strings.IndexAny(dataType, " ("); i >= 0 {
+		dataType = dataType[:i]
+	}
+
+	switch dataType {
+	case "int", "integer", "smallint", "bigint", "tinyint", "mediumint",
+		"int2", "int4", "int8", "numeric", "decimal", "real", "float",
+		"float4", "float8", "double", "money":
+		return 0
+	case "bool", "boolean":
+		return false
+	case "timestamp", "timestamptz", "datetime", "date":
+		return time.Unix(0, 0).UTC()
+	case "time", "timetz":
+		return "00:00:00"
+	case "json", "jsonb":
+		return "{}"
+	case "uuid":
+		return "00000000-0000-0000-0000-000000000000"
+	case "bytea", "blob", "binary", "varbinary":
+		return []byte{}
+	}
+	return ""
+}
+
+// fillLogf logs the columns filled by FillRequired
+func (o *options) fillLogf(format string, args ...interface{}) {
+	if o.fillLog != nil {
+		o.fillLog(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,23 @@
+package fixtures
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaceholderValue(t *testing.T) {
+	assert.Equal(t, 0, placeholderValue("INT"))
+	assert.Equal(t, 0, placeholderValue("numeric(10,2)"))
+	assert.Equal(t, 0, placeholderValue("double precision"))
+	assert.Equal(t, false, placeholderValue("BOOL"))
+	assert.Equal(t, time.Unix(0, 0).UTC(), placeholderValue("timestamp with time zone"))
+	assert.Equal(t, time.Unix(0, 0).UTC(), placeholderValue("DATETIME"))
+	assert.Equal(t, "00:00:00", placeholderValue("time without time zone"))
+	assert.Equal(t, "{}", placeholderValue("jsonb"))
+	assert.Equal(t, "{}", placeholderValue("integer[]"))
+	assert.Equal(t, []byte{}, placeholderValue("bytea"))
+	assert.Equal(t, "", placeholderValue("character varying(50)"))
+	assert.Equal(t, "", placeholderValue("interval"))
+}
@@ -0,0 +1,155 @@
+package fixtures
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// generatorFuncs are the template functions available in generated rows
+var generatorFuncs = template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+	"sub": func(a, b int) int { return a - b },
+	"mul": func(a, b int) int { return a * b },
+	"div": func(a, b int) int { return a / b },
+	"mod": func(a, b int) int { return a % b },
+}
+
+// generatorDelims returns the delimiters of the templates of generated rows.
+// They are {{ }} unless the fixture is run through the Template option, which
+// executes every {{ }} action of the file first, so generated rows use [[ ]]
+// instead, such as id: '[[add 100 .N]]'.
+func generatorDelims(o *options) (string, string) {
+	if o.template != nil {
+		return "[[", "]]"
+	}
+	return "{{", "}}"
+}
+
+// generatorData is the dot of the templates of generated rows, N counts from 1
+type generatorData struct {
+	N int
+}
+
+// generatorTemplate holds the data and delimiters of the templates of a
+// generated row
+type generatorTemplate struct {
+	data        generatorData
+	left, right string
+}
+
+// expandGenerators replaces every row with a count by that many rows, with
+// the templates in its values, label and extends executed for each of them
+func expandGenerators(document *fixtureDocument, o *options) error {
+	left, right := generatorDelims(o)
+
+	var rows []Row
+	for i, row := range document.Rows {
+		if row.Count == 0 {
+			rows = append(rows, row)
+			continue
+		}
+		if row.Count < 0 {
+			return NewProcessingError(i+1, fmt.Errorf("Invalid count %d", row.Count))
+		}
+
+		for n := 1; n <= row.Count; n++ {
+			generated, err := generateRow(row, generatorTemplate{data: generatorData{N: n}, left: left, right: right})
+			if err != nil {
+				return NewProcessingError(i+1, err)
+			}
+			rows = append(rows, *generated)
+		}
+	}
+	document.Rows = rows
+	return nil
+}
+
+// generateRow returns a copy of a row with its templates executed
+func generateRow(row Row, gen generatorTemplate) (*Row, error) {
+	generated := row
+	generated.Count = 0
+
+	var err error
+	if generated.Label, err = expandString(row.Label, gen); err != nil {
+		return nil, err
+	}
+	if generated.Extends, err = expandString(row.Extends, gen); err != nil {
+		return nil, err
+	}
+	if generated.PK, err = expandValues(row.PK, gen); err != nil {
+		return nil, err
+	}
+	if generated.Match, err = expandValues(row.Match, gen); err != nil {
+		return nil, err
+	}
+	if generated.Fields, err = expandValues(row.Fields, gen); err != nil {
+		return nil, err
+	}
+	return &generated, nil
+}
+
+// expandValues returns a copy of values with their templates executed
+func expandValues(values map[string]interface{}, gen generatorTemplate) (map[string]interface{}, error) {
+	if values == nil {
+		return nil, nil
+	}
+
+	expanded := make(map[string]interface{}, len(values))
+	for column, value := range values {
+		// Templates in tagged values, such as !ref users.user{{.N}}.id
+		if tag, s, ok := tagValue(value); ok {
+			s, err := expandString(s, gen)
+			if err != nil {
+				return nil, fmt.Errorf("Error generating %s: %s", column, err)
+			}
+			expanded[column] = map[interface{}]interface{}{"!" + tag: s}
+			continue
+		}
+
+		s, ok := value.(string)
+		if !ok || !strings.Contains(s, gen.left) {
+			expanded[column] = value
+			continue
+		}
+		s, err := expandString(s, gen)
+		if err != nil {
+			return nil, fmt.Errorf("Error generating %s: %s", column, err)
+		}
+		expanded[column] = resolveScalar(s)
+	}
+	return expanded, nil
+}
+
+// expandString executes a template
+func expandString(s string, gen generatorTemplate) (string, error) {
+	if !strings.Contains(s, gen.left) {
+		return s, nil
+	}
+	tmpl, err := template.New("count").Delims(gen.left, gen.right).Funcs(generatorFuncs).Parse(s)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, gen.data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// resolveScalar gives a generated value the type YAML would, so "101" is an
+// integer, leaving anything but scalars as strings
+func resolveScalar(s string) interface{} {
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(s), &value); err != nil {
+		return s
+	}
+	switch value.(type) {
+	case int, int64, uint64, float64, bool:
+		return value
+	}
+	return s
+}
@@ -0,0 +1,190 @@
+package fixtures
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Dump selects every row of the given tables, ordered by primary key, and
+// returns them as a YAML fixture that can be passed back to Load
+func Dump(db *sql.DB, driver string, tables ...string) ([]byte, error) {
+	var rows []Row
+	for _, table := range tables {
+		tableRows, err := dumpTable(db, driver, table)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, tableRows...)
+	}
+
+	return yaml.Marshal(rows)
+}
+
+// Golden compares the given tables with a YAML golden file in strict mode
+// and fails the test with a readable diff if they differ. When the
+// UpdateGolden option is set, the golden file is rewritten from the database
+// instead. Golden files use the fixture format, so they can be loaded as
+// fixtures.
+func Golden(t testing.TB, filename string, db *sql.DB, driver string, tables []string, opts ...Option) {
+	t.Helper()
+
+	o := newOptions(opts)
+	if o.update {
+		data, err := Dump(db, driver, tables...)
+		if err != nil {
+			t.Fatalf("Error dumping golden file %s: %s", filename, err)
+		}
+		if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+			t.Fatalf("Error writing golden file %s: %s", filename, err)
+		}
+		return
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		t.Fatalf("Golden file %s does not exist, run with the UpdateGolden option to create it", filename)
+	}
+	if err != nil {
+		t.Fatal(NewFileError(filename, err))
+	}
+
+	rows, err := parseFixture(data, filepath.Dir(filename), o)
+	if err != nil {
+		t.Fatal(NewFileError(filename, err))
+	}
+
+	// Tables that are empty in the database have no rows in the golden file
+	for i := range rows {
+		rows[i].Init()
+		if !containsString(tables, rows[i].tableName()) {
+			t.Fatalf("Golden file %s contains table %s which is not being compared", filename, rows[i].tableName())
+		}
+	}
+	for _, table := range tables {
+		if !containsTable(rows, table) {
+			var count int
+			err := db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %s`, quoteTable(driver, table))).Scan(&count)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count > 0 {
+				t.Fatalf("Golden file %s has no rows for table %s, but the database has %d", filename, table, count)
+			}
+		}
+	}
+
+	o.strict = true
+	if err := verifyRows(rows, nil, db, driver, o); err != nil {
+		t.Fatalf("Golden file %s: %s", filename, err)
+	}
+}
+
+// dumpTable selects every row of a table as fixture rows
+func dumpTable(db *sql.DB, driver string, table string) ([]Row, error) {
+	pkColumns, err := primaryKeyColumns(db, driver, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkColumns) == 0 {
+		return nil, fmt.Errorf("Table %s has no primary key", table)
+	}
+
+	escapedColumns := make([]string, len(pkColumns))
+	for i, column := range pkColumns {
+		escapedColumns[i] = quoteIdentifier(driver, column)
+	}
+	selectQuery := fmt.Sprintf(
+		`SELECT * FROM %s ORDER BY %s`,
+		quoteTable(driver, table),
+		strings.Join(escapedColumns, ", "),
+	)
+
+	dbRows, err := db.Query(selectQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer dbRows.Close()
+
+	columns, err := dbRows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	for dbRows.Next() {
+		values := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := dbRows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		row := Row{
+			Table:  table,
+			PK:     make(map[string]interface{}),
+			Fields: make(map[string]interface{}),
+		}
+		for i, column := range columns {
+			if containsString(pkColumns, column) {
+				row.PK[column] = dumpValue(values[i])
+			} else {
+				row.Fields[column] = dumpValue(values[i])
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, dbRows.Err()
+}
+
+// dumpValue converts a database value to a value that survives a round trip
+// through YAML. Text that would be read as a relative time or a value
+// function is dumped as a literal.
+func dumpValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return dumpValue(string(v))
+	case string:
+		if _, _, ok := parseValueFunc(v); ok {
+			return Literal(v)
+		}
+		if _, ok, _ := parseRelativeTime(v, time.Time{}); ok {
+			return Literal(v)
+		}
+		return v
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return v
+	}
+}
+
+// containsString returns true if the slice contains the string
+func containsString(slice []string, s string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// containsTable returns true if one of the rows belongs to the table
+func containsTable(rows []Row, table string) bool {
+	for _, row := range rows {
+		if row.tableName() == table {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package fixtures
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	// Driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var testGoldenFile = "/tmp/fixtures_test.golden.yml"
+
+func TestDumpWorksWithValidDataSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a test schema
+	_, err = db.Exec(testSchemaSQLite)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load the fixture
+	err = Load([]byte(testData), db, "sqlite")
+	assert.Nil(t, err)
+
+	// Dump the tables without timestamps
+	data, err := Dump(db, "sqlite", "join_table")
+	assert.Nil(t, err)
+	assert.Equal(t, `- table: join_table
+  pk:
+    other_id: 2
+    some_id: 1
+`, string(data))
+
+	// Dumped timestamps can be loaded back as a fixture
+	data, err = Dump(db, "sqlite", "some_table", "other_table")
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(data), "updated_at: null"))
+	err = Load(data, db, "sqlite")
+	assert.Nil(t, err)
+	err = Verify(data, db, "sqlite", Strict())
+	assert.Nil(t, err)
+
+	// Text that looks like fixture syntax is dumped as a literal
+	_, err = db.Exec(`INSERT INTO some_table(id, string_field, boolean_field) VALUES(100, 'NOW+1d', 1)`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO string_key_table(id) VALUES('NOW()')`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err = Dump(db, "sqlite", "some_table", "string_key_table")
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(data), "string_field:\n      '!literal': NOW+1d"))
+	assert.True(t, strings.Contains(string(data), "id:\n      '!literal': NOW()"))
+	err = Verify(data, db, "sqlite", Strict())
+	assert.Nil(t, err)
+	err = Load(data, db, "sqlite")
+	assert.Nil(t, err)
+	var stringField string
+	err = db.QueryRow(`SELECT string_field FROM some_table WHERE id = 100`).Scan(&stringField)
+	assert.Nil(t, err)
+	assert.Equal(t, "NOW+1d", stringField)
+
+	// Tables without a primary key can not be dumped
+	_, err = db.Exec(`CREATE TABLE no_pk_table(foo INT)`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = Dump(db, "sqlite", "no_pk_table")
+	assert.EqualError(t, err, "Table no_pk_table has no primary key")
+}
+
+func TestGoldenWorksWithValidDataSQLite(t *testing.T) {
+	// Delete the test database and golden file
+	os.Remove(testSQLiteDb)
+	os.Remove(testGoldenFile)
+	defer os.Remove(testGoldenFile)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a test schema
+	_, err = db.Exec(testSchemaSQLite)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load the fixture
+	err = Load([]byte(testData), db, "sqlite")
+	assert.Nil(t, err)
+
+	tables := []string{"some_table", "other_table", "join_table", "string_key_table"}
+
+	// Comparing with a missing golden file fails
+	tb := runFakeTB(func(tb *fakeTB) {
+		Golden(tb, testGoldenFile, db, "sqlite", tables)
+	})
+	assert.True(t, tb.failed)
+	assert.Equal(t, "Golden file /tmp/fixtures_test.golden.yml does not exist, "+
+		"run with the UpdateGolden option to create it", tb.message)
+
+	// Updating writes the golden file
+	Golden(t, testGoldenFile, db, "sqlite", tables, UpdateGolden(true))
+	data, err := ioutil.ReadFile(testGoldenFile)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(data), "string_field: foobar"))
+
+	// The database now matches the golden file
+	Golden(t, testGoldenFile, db, "sqlite", tables)
+
+	// Changing the database makes the comparison fail
+	_, err = db.Exec(`INSERT INTO join_table(some_id, other_id) VALUES(3, 4)`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tb = runFakeTB(func(tb *fakeTB) {
+		Golden(tb, testGoldenFile, db, "sqlite", tables)
+	})
+	assert.True(t, tb.failed)
+	assert.Equal(t, "Golden file /tmp/fixtures_test.golden.yml: "+
+		"Database does not match fixture (1 rows differ):\n"+
+		"  unexpected row, join_table (other_id=4, some_id=3)", tb.message)
+
+	// Empty tables must be empty in the database too
+	_, err = db.Exec(`DELETE FROM join_table`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	Golden(t, testGoldenFile, db, "sqlite", tables, UpdateGolden(true))
+	_, err = db.Exec(`INSERT INTO join_table(some_id, other_id) VALUES(3, 4)`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tb = runFakeTB(func(tb *fakeTB) {
+		Golden(tb, testGoldenFile, db, "sqlite", tables)
+	})
+	assert.True(t, tb.failed)
+	assert.Equal(t, "Golden file /tmp/fixtures_test.golden.yml has no rows "+
+		"for table join_table, but the database has 1", tb.message)
+}
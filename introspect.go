@@ -0,0 +1,215 @@
+package fixtures
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// primaryKeyColumns returns the primary key column names of a table in key
+// order, or none if it has no primary key. The table may be schema-qualified
+// with dotted syntax.
+func primaryKeyColumns(q queryer, driver string, table string) ([]string, error) {
+	var columns []string
+	schema, name := splitTable(table)
+
+	switch driver {
+	case postgresDriver:
+		rows, err := q.Query(`
+			SELECT a.attname
+			FROM pg_index i
+			JOIN pg_attribute a
+				ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+			WHERE i.indrelid = $1::regclass AND i.indisprimary
+			ORDER BY array_position(i.indkey, a.attnum)
+		`, quoteTable(driver, table))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var column string
+			if err := rows.Scan(&column); err != nil {
+				return nil, err
+			}
+			columns = append(columns, column)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	case sqliteDriver, sqlite3Driver:
+		pragma := "PRAGMA "
+		if schema != "" {
+			pragma += quoteIdentifier(driver, schema) + "."
+		}
+		rows, err := q.Query(fmt.Sprintf(`%stable_info(%s)`, pragma, quoteIdentifier(driver, name)))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		// Primary key columns have a non zero 1-based position in the key
+		positions := make(map[int]string)
+		for rows.Next() {
+			var (
+				cid          int
+				name         string
+				columnType   string
+				notNull      bool
+				defaultValue interface{}
+				pk           int
+			)
+			if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+				return nil, err
+			}
+			if pk > 0 {
+				positions[pk] = name
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		for i := 1; i <= len(positions); i++ {
+			columns = append(columns, positions[i])
+		}
+	case mysqlDriver:
+		rows, err := q.Query(`
+			SELECT COLUMN_NAME
+			FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE
+			WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE())
+				AND TABLE_NAME = ?
+				AND CONSTRAINT_NAME = 'PRIMARY'
+			ORDER BY ORDINAL_POSITION
+		`, schema, name)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var column string
+			if err := rows.Scan(&column); err != nil {
+				return nil, err
+			}
+			columns = append(columns, column)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Driver %s does not support introspection", driver)
+	}
+
+	return columns, nil
+}
+
+// requiredColumn is a NOT NULL column without a default
+type requiredColumn struct {
+	name     string
+	dataType string
+}
+
+// requiredColumns returns the NOT NULL columns of a table that have no
+// default and are not generated by the database, such as identity and auto
+// increment columns. The table may be schema-qualified with dotted syntax.
+func requiredColumns(q queryer, driver string, table string) ([]requiredColumn, error) {
+	var columns []requiredColumn
+	schema, name := splitTable(table)
+
+	switch driver {
+	case postgresDriver:
+		rows, err := q.Query(`
+			SELECT a.attname, format_type(a.atttypid, a.atttypmod)
+			FROM pg_attribute a
+			WHERE a.attrelid = $1::regclass
+				AND a.attnum > 0
+				AND NOT a.attisdropped
+				AND a.attnotnull
+				AND NOT a.atthasdef
+				AND a.attidentity = ''
+			ORDER BY a.attnum
+		`, quoteTable(driver, table))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var column requiredColumn
+			if err := rows.Scan(&column.name, &column.dataType); err != nil {
+				return nil, err
+			}
+			columns = append(columns, column)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	case sqliteDriver, sqlite3Driver:
+		pragma := "PRAGMA "
+		if schema != "" {
+			pragma += quoteIdentifier(driver, schema) + "."
+		}
+		rows, err := q.Query(fmt.Sprintf(`%stable_info(%s)`, pragma, quoteIdentifier(driver, name)))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				cid          int
+				name         string
+				columnType   string
+				notNull      bool
+				defaultValue interface{}
+				pk           int
+			)
+			if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+				return nil, err
+			}
+			// INTEGER primary keys are aliases of the generated rowid
+			if pk > 0 && strings.EqualFold(columnType, "INTEGER") {
+				continue
+			}
+			if notNull && defaultValue == nil {
+				columns = append(columns, requiredColumn{name: name, dataType: columnType})
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	case mysqlDriver:
+		rows, err := q.Query(`
+			SELECT COLUMN_NAME, DATA_TYPE
+			FROM INFORMATION_SCHEMA.COLUMNS
+			WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE())
+				AND TABLE_NAME = ?
+				AND IS_NULLABLE = 'NO'
+				AND COLUMN_DEFAULT IS NULL
+				AND EXTRA NOT LIKE '%auto_increment%'
+				AND EXTRA NOT LIKE '%GENERATED%'
+			ORDER BY ORDINAL_POSITION
+		`, schema, name)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var column requiredColumn
+			if err := rows.Scan(&column.name, &column.dataType); err != nil {
+				return nil, err
+			}
+			columns = append(columns, column)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Driver %s does not support introspection", driver)
+	}
+
+	return columns, nil
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v2"
@@ -20,12 +22,29 @@ func NewFileError(filename string, cause error) error {
 }
 
 // Load processes a YAML fixture and inserts/updates the database accordingly
-func Load(data []byte, db *sql.DB, driver string) error {
+func Load(data []byte, db *sql.DB, driver string, opts ...Option) error {
+	return load("", data, db, driver, newOptions(opts))
+}
+
+// load processes a YAML fixture read from filename, which is empty for data
+// passed to Load
+func load(filename string, data []byte, db *sql.DB, driver string, o *options) error {
+	// Templates, value functions and relative times of every row use the same
+	// reference time
+	now := o.clock()
+
+	// Execute the fixture template
+	data, err := expandTemplate(filename, data, now, o)
+	if err != nil {
+		return err
+	}
+
 	// Unmarshal the YAML data into a []Row slice
-	var rows []Row
-	if err := yaml.Unmarshal(data, &rows); err != nil {
+	document, err := parseDocument(data, fixtureDir(filename), o)
+	if err != nil {
 		return err
 	}
+	rows := document.Rows
 
 	// Begin a transaction
 	tx, err := db.Begin()
@@ -33,15 +52,109 @@ func Load(data []byte, db *sql.DB, driver string) error {
 		return err
 	}
 
+	// Changes are only added to the undo log once the transaction commits
+	var undo []UndoEntry
+
+	// Tables whose sequences are reset at the end of the load
+	var tables []Row
+
+	// Keys generated for rows without a primary key
+	keys := make(map[int]map[string]interface{})
+
+	// Labelled rows, their derived keys and table defaults of this load, and
+	// of earlier loads sharing the result
+	loaded := make(labels)
+	derived := make(labelKeys)
+	var earlier labels
+	var earlierKeys labelKeys
+	defaults := []tableDefaults{document.Defaults}
+	if o.result != nil {
+		earlier = o.result.labels
+		earlierKeys = o.result.labelKeys
+		defaults = []tableDefaults{o.result.defaults, document.Defaults}
+	}
+
+	// Required columns of the tables, and the ones filled, for FillRequired
+	required := make(map[string][]requiredColumn)
+	filled := make(map[string][]string)
+
+	// Fake data of rows without a key is seeded once per load
+	source := newValueSource(o)
+
 	// Iterate over rows define in the fixture
 	for i, row := range rows {
+		// Merge table defaults and the fields of the row it extends
+		if err := inheritFields(&row, defaults, loaded, earlier); err != nil {
+			tx.Rollback() // rollback the transaction
+			return NewProcessingError(i+1, err)
+		}
+
+		// Derive the primary key from the label if asked to
+		if err := setLabelKey(tx, driver, &row, o, derived, earlierKeys); err != nil {
+			tx.Rollback() // rollback the transaction
+			return NewProcessingError(i+1, err)
+		}
+
+		// Replace references to labelled rows with their values
+		if err := resolveRefs(tx, driver, &row, loaded, earlier); err != nil {
+			tx.Rollback() // rollback the transaction
+			return NewProcessingError(i+1, err)
+		}
+
 		// Load internat struct variables
-		row.Init()
+		row.now = now
+		row.source = source
+		if err := row.Init(); err != nil {
+			tx.Rollback() // rollback the transaction
+			return NewProcessingError(i+1, err)
+		}
+
+		// Rows without a primary key or match columns are always inserted, let
+		// the database generate the key
+		if len(row.key()) == 0 {
+			if o.fillRequired {
+				if err := fillRequired(tx, driver, &row, required, filled); err != nil {
+					tx.Rollback() // rollback the transaction
+					return NewProcessingError(i+1, err)
+				}
+			}
+			key, err := insertRow(tx, driver, &row)
+			if err != nil {
+				tx.Rollback() // rollback the transaction
+				return NewProcessingError(i+1, err)
+			}
+			keys[i] = key
+
+			if o.undo != nil {
+				if len(key) == 0 {
+					tx.Rollback() // rollback the transaction
+					return NewProcessingError(i+1, fmt.Errorf(
+						"Table %s has no primary key, the row can not be undone",
+						row.tableName(),
+					))
+				}
+				undo = append(undo, UndoEntry{
+					Table:    row.Table,
+					Schema:   row.Schema,
+					PK:       key,
+					Inserted: true,
+				})
+			}
+
+			if err := loaded.add(&row, key); err != nil {
+				tx.Rollback() // rollback the transaction
+				return NewProcessingError(i+1, err)
+			}
+			if !containsTable(tables, row.tableName()) {
+				tables = append(tables, row)
+			}
+			continue
+		}
 
 		// Run a SELECT query to find out if we need to insert or UPDATE
 		selectQuery := fmt.Sprintf(
-			`SELECT COUNT(*) FROM "%s" WHERE %s`,
-			row.Table,
+			`SELECT COUNT(*) FROM %s WHERE %s`,
+			row.GetTable(driver),
 			row.GetWhere(driver, 0),
 		)
 		var count int
@@ -53,31 +166,50 @@ func Load(data []byte, db *sql.DB, driver string) error {
 
 		if count == 0 {
 			// Primary key not found, let's run an INSERT query
-			insertQuery := fmt.Sprintf(
-				`INSERT INTO "%s"(%s) VALUES(%s)`,
-				row.Table,
-				strings.Join(row.GetInsertColumns(), ", "),
-				strings.Join(row.GetInsertPlaceholders(driver), ", "),
-			)
-			_, err := tx.Exec(insertQuery, row.GetInsertValues()...)
+			if o.fillRequired {
+				if err := fillRequired(tx, driver, &row, required, filled); err != nil {
+					tx.Rollback() // rollback the transaction
+					return NewProcessingError(i+1, err)
+				}
+			}
+			key, err := insertRow(tx, driver, &row)
 			if err != nil {
 				tx.Rollback() // rollback the transaction
 				return NewProcessingError(i+1, err)
 			}
-			if driver == postgresDriver && row.GetInsertColumns()[0] == "\"id\"" {
-				err = fixPostgresPKSequence(tx, row.Table, "id")
+			if len(row.PK) == 0 {
+				keys[i] = key
+			}
+
+			if o.undo != nil {
+				entryKey, err := undoKey(tx, driver, &row)
 				if err != nil {
-					tx.Rollback()
+					tx.Rollback() // rollback the transaction
 					return NewProcessingError(i+1, err)
 				}
+				undo = append(undo, UndoEntry{
+					Table:    row.Table,
+					Schema:   row.Schema,
+					PK:       entryKey,
+					Inserted: true,
+				})
 			}
 		} else {
+			if o.undo != nil {
+				entry, err := selectPreImage(tx, driver, &row)
+				if err != nil {
+					tx.Rollback() // rollback the transaction
+					return NewProcessingError(i+1, err)
+				}
+				undo = append(undo, *entry)
+			}
+
 			// Primary key found, let's run UPDATE query
 			updateQuery := fmt.Sprintf(
-				`UPDATE "%s" SET %s WHERE %s`,
-				row.Table,
+				`UPDATE %s SET %s WHERE %s`,
+				row.GetTable(driver),
 				strings.Join(row.GetUpdatePlaceholders(driver), ", "),
-				row.GetWhere(driver, row.GetUpdateColumnsLength()),
+				row.GetWhere(driver, len(row.GetUpdateValues())),
 			)
 			values := append(row.GetUpdateValues(), row.GetPKValues()...)
 			_, err := tx.Exec(updateQuery, values...)
@@ -85,14 +217,38 @@ func Load(data []byte, db *sql.DB, driver string) error {
 				tx.Rollback() // rollback the transaction
 				return NewProcessingError(i+1, err)
 			}
-			if driver == postgresDriver && row.GetUpdateColumns()[0] == "\"id\"" {
-				err = fixPostgresPKSequence(tx, row.Table, "id")
+
+			// Rows found by their match columns report their primary key
+			if len(row.PK) == 0 {
+				key, err := selectKey(tx, driver, &row)
 				if err != nil {
-					tx.Rollback()
+					tx.Rollback() // rollback the transaction
 					return NewProcessingError(i+1, err)
 				}
+				keys[i] = key
 			}
 		}
+
+		key := row.PK
+		if len(key) == 0 {
+			key = keys[i]
+		}
+		if err := loaded.add(&row, key); err != nil {
+			tx.Rollback() // rollback the transaction
+			return NewProcessingError(i+1, err)
+		}
+		if !containsTable(tables, row.tableName()) {
+			tables = append(tables, row)
+		}
+	}
+
+	// Reset sequences of the touched tables, MySQL commits the transaction on
+	// ALTER TABLE so it has to wait until the rows have been committed
+	if driver != mysqlDriver {
+		if err := resetSequences(tx, driver, tables); err != nil {
+			tx.Rollback() // rollback the transaction
+			return err
+		}
 	}
 
 	// Commit the transaction
@@ -101,11 +257,218 @@ func Load(data []byte, db *sql.DB, driver string) error {
 		return err
 	}
 
+	if driver == mysqlDriver {
+		if err := resetSequences(db, driver, tables); err != nil {
+			return err
+		}
+	}
+
+	logFilled(o, filled)
+	if o.undo != nil {
+		o.undo.Entries = append(o.undo.Entries, undo...)
+	}
+	if o.result != nil {
+		o.result.add(len(rows), keys, loaded, derived, document.Defaults)
+	}
+
 	return nil
 }
 
+// insertRow runs an INSERT query for a row. For rows without a primary key it
+// returns the key generated by the database, using RETURNING on Postgres and
+// the last insert ID elsewhere.
+func insertRow(tx *sql.Tx, driver string, row *Row) (map[string]interface{}, error) {
+	insertQuery := fmt.Sprintf(
+		`INSERT INTO %s(%s) VALUES(%s)`,
+		row.GetTable(driver),
+		strings.Join(row.quotedInsertColumns(driver), ", "),
+		strings.Join(row.GetInsertPlaceholders(driver), ", "),
+	)
+	if len(row.quotedInsertColumns(driver)) == 0 && driver != mysqlDriver {
+		insertQuery = fmt.Sprintf(`INSERT INTO %s DEFAULT VALUES`, row.GetTable(driver))
+	}
+
+	if len(row.PK) > 0 {
+		_, err := tx.Exec(insertQuery, row.GetInsertValues()...)
+		return row.PK, err
+	}
+
+	pkColumns, err := primaryKeyColumns(tx, driver, row.tableName())
+	if err != nil {
+		return nil, err
+	}
+	escapedColumns := make([]string, len(pkColumns))
+	for i, column := range pkColumns {
+		escapedColumns[i] = quoteIdentifier(driver, column)
+	}
+	key := make(map[string]interface{})
+	values := make([]interface{}, len(pkColumns))
+	dest := make([]interface{}, len(pkColumns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	if driver == postgresDriver && len(pkColumns) > 0 {
+		insertQuery += " RETURNING " + strings.Join(escapedColumns, ", ")
+		if err := tx.QueryRow(insertQuery, row.GetInsertValues()...).Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i, column := range pkColumns {
+			key[column] = values[i]
+		}
+		return key, nil
+	}
+
+	result, err := tx.Exec(insertQuery, row.GetInsertValues()...)
+	if err != nil || len(pkColumns) == 0 {
+		return key, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	switch driver {
+	case sqliteDriver, sqlite3Driver:
+		// The last insert ID is the rowid, which may not be the primary key
+		selectQuery := fmt.Sprintf(
+			`SELECT %s FROM %s WHERE rowid = ?`,
+			strings.Join(escapedColumns, ", "),
+			row.GetTable(driver),
+		)
+		if err := tx.QueryRow(selectQuery, id).Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i, column := range pkColumns {
+			key[column] = values[i]
+		}
+	default:
+		// The last insert ID is the AUTO_INCREMENT column
+		if len(pkColumns) == 1 {
+			key[pkColumns[0]] = id
+		}
+	}
+	return key, nil
+}
+
+// selectKey returns the primary key of the database row found by the match
+// columns of a row
+func selectKey(q queryer, driver string, row *Row) (map[string]interface{}, error) {
+	pkColumns, err := primaryKeyColumns(q, driver, row.tableName())
+	if err != nil || len(pkColumns) == 0 {
+		return nil, err
+	}
+	escapedColumns := make([]string, len(pkColumns))
+	for i, column := range pkColumns {
+		escapedColumns[i] = quoteIdentifier(driver, column)
+	}
+	selectQuery := fmt.Sprintf(
+		`SELECT %s FROM %s WHERE %s`,
+		strings.Join(escapedColumns, ", "),
+		row.GetTable(driver),
+		row.GetWhere(driver, 0),
+	)
+
+	values := make([]interface{}, len(pkColumns))
+	dest := make([]interface{}, len(pkColumns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := q.QueryRow(selectQuery, row.GetPKValues()...).Scan(dest...); err != nil {
+		return nil, err
+	}
+	key := make(map[string]interface{})
+	for i, column := range pkColumns {
+		key[column] = values[i]
+	}
+	return key, nil
+}
+
+// fixtureDocument is a fixture document with file level settings, which lists
+// its rows under the rows key. Defaults holds fields merged into every row of
+// a table.
+type fixtureDocument struct {
+	Schema   string        `yaml:"schema"`
+	Defaults tableDefaults `yaml:"defaults"`
+	Rows     []Row         `yaml:"rows"`
+}
+
+// parseFixture unmarshals the rows of a YAML fixture. Paths in tagged values
+// are relative to dir.
+func parseFixture(data []byte, dir string, o *options) ([]Row, error) {
+	document, err := parseDocument(data, dir, o)
+	if err != nil {
+		return nil, err
+	}
+	return document.Rows, nil
+}
+
+// parseDocument unmarshals a YAML fixture, which is either a list of rows or
+// a mapping with file level settings and the rows. Paths in tagged values are
+// relative to dir.
+func parseDocument(data []byte, dir string, o *options) (*fixtureDocument, error) {
+	data = rewriteTags(data)
+
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	document := new(fixtureDocument)
+	if _, ok := value.(map[interface{}]interface{}); !ok {
+		if err := yaml.Unmarshal(data, &document.Rows); err != nil {
+			return nil, err
+		}
+		if err := decodeTags(document, dir); err != nil {
+			return nil, err
+		}
+		return document, expandGenerators(document, o)
+	}
+
+	// Mappings are only file level settings and rows, a single row written
+	// without a leading - is a mistake
+	mapping := value.(map[interface{}]interface{})
+	var unknown []string
+	for key := range mapping {
+		if key != "schema" && key != "defaults" && key != "rows" {
+			unknown = append(unknown, fmt.Sprint(key))
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("Unknown fixture keys %s, expected schema, defaults or rows", strings.Join(unknown, ", "))
+	}
+	if _, ok := mapping["rows"]; !ok {
+		return nil, fmt.Errorf("Fixture has no rows key, rows must be a list")
+	}
+
+	if err := yaml.Unmarshal(data, document); err != nil {
+		return nil, err
+	}
+
+	// Apply the default schema to rows without one
+	for i := range document.Rows {
+		if document.Rows[i].Schema == "" && !strings.Contains(document.Rows[i].Table, ".") {
+			document.Rows[i].Schema = document.Schema
+		}
+	}
+	if err := decodeTags(document, dir); err != nil {
+		return nil, err
+	}
+	return document, expandGenerators(document, o)
+}
+
+// fixtureDir returns the directory of a fixture file, or the current
+// directory for data passed directly
+func fixtureDir(filename string) string {
+	if filename == "" {
+		return "."
+	}
+	return filepath.Dir(filename)
+}
+
 // LoadFile ...
-func LoadFile(filename string, db *sql.DB, driver string) error {
+func LoadFile(filename string, db *sql.DB, driver string, opts ...Option) error {
 	// Read fixture data from the file
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -113,40 +476,20 @@ func LoadFile(filename string, db *sql.DB, driver string) error {
 	}
 
 	// Insert the fixture data
-	return Load(data, db, driver)
+	return load(filename, data, db, driver, newOptions(opts))
 }
 
 // LoadFiles ...
-func LoadFiles(filenames []string, db *sql.DB, driver string) error {
+func LoadFiles(filenames []string, db *sql.DB, driver string, opts ...Option) error {
+	// Share labels across the files, unless the caller records the result,
+	// a single reference time and the SEQ() counters
+	opts = append([]Option{RecordResult(new(Result))}, opts...)
+	opts = append(opts, fixedClock(newOptions(opts).clock()), sharedSequences(make(map[string]int64)))
+
 	for _, filename := range filenames {
-		if err := LoadFile(filename, db, driver); err != nil {
+		if err := LoadFile(filename, db, driver, opts...); err != nil {
 			return err
 		}
 	}
 	return nil
 }
-
-// fixPostgresPKSequence
-func fixPostgresPKSequence(tx *sql.Tx, table string, column string) error {
-	// Query for the qualified sequence name
-	var seqName *string
-	err := tx.QueryRow(`
-		SELECT pg_get_serial_sequence($1, $2)
-	`, table, column).Scan(&seqName)
-
-	if err != nil {
-		return err
-	}
-
-	if seqName == nil {
-		// No sequence to fix
-		return nil
-	}
-
-	// Set the sequence
-	_, err = tx.Exec(fmt.Sprintf(`
-		SELECT pg_catalog.setval($1, (SELECT MAX("%s") FROM "%s"))
-	`, column, table), *seqName)
-
-	return err
-}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os/exec"
+	"strings"
 	"testing"
 	"time"
 
@@ -523,3 +524,248 @@ func dropPostgresDB(dbUser, dbName string) {
 	fmt.Println(dropDbCmd)
 	exec.Command("sh", "-c", dropDbCmd).Output()
 }
+
+func TestLoadWorksWithSchemaQualifiedTablesPostgres(t *testing.T) {
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to a test Postgres db
+	db, err = rebuildDatabasePostgres(testPostgresDbUser, testPostgresDbName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a test schema, with a serial primary key in a separate schema
+	_, err = db.Exec(testSchemaPostgres + `
+		CREATE SCHEMA billing;
+		CREATE TABLE billing.invoices(id SERIAL PRIMARY KEY, amount INT NOT NULL);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Let's load the fixture, since the database is empty, this should run inserts
+	err = Load([]byte(strings.Replace(testSchemaQualifiedData, "main.", "public.", 1)), db, "postgres")
+	assert.Nil(t, err)
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM billing.invoices").Scan(&count)
+	assert.Equal(t, 3, count)
+	db.QueryRow("SELECT COUNT(*) FROM public.some_table").Scan(&count)
+	assert.Equal(t, 1, count)
+
+	// The sequence of the qualified table has been fixed
+	var id int
+	err = db.QueryRow("INSERT INTO billing.invoices(amount) VALUES(400) RETURNING id").Scan(&id)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, id)
+}
+
+func TestLoadResetsSequencesPostgres(t *testing.T) {
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to a test Postgres db
+	db, err = rebuildDatabasePostgres(testPostgresDbUser, testPostgresDbName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create tables with serial and identity columns not called id
+	_, err = db.Exec(`
+		CREATE TABLE users(user_id SERIAL PRIMARY KEY, name VARCHAR(50));
+		CREATE TABLE composite(tenant_id INT, item_id SERIAL, PRIMARY KEY(tenant_id, item_id));
+		CREATE TABLE identity(id INT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY);
+		CREATE TABLE counters(name VARCHAR(50) PRIMARY KEY, number SERIAL);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Let's load the fixture
+	err = Load([]byte(`
+- table: 'users'
+  pk:
+    user_id: 5
+  fields:
+    name: 'foo'
+- table: 'composite'
+  pk:
+    tenant_id: 1
+    item_id: 6
+- table: 'identity'
+  pk:
+    id: 7
+- table: 'counters'
+  pk:
+    name: 'foo'
+  fields:
+    number: 8
+`), db, "postgres")
+	assert.Nil(t, err)
+
+	// Every sequence continues after the fixture rows
+	var id int
+	db.QueryRow("INSERT INTO users(name) VALUES('bar') RETURNING user_id").Scan(&id)
+	assert.Equal(t, 6, id)
+	db.QueryRow("INSERT INTO composite(tenant_id) VALUES(1) RETURNING item_id").Scan(&id)
+	assert.Equal(t, 7, id)
+	db.QueryRow("INSERT INTO identity DEFAULT VALUES RETURNING id").Scan(&id)
+	assert.Equal(t, 8, id)
+	db.QueryRow("INSERT INTO counters(name) VALUES('bar') RETURNING number").Scan(&id)
+	assert.Equal(t, 9, id)
+}
+
+func TestLoadCapturesGeneratedKeysPostgres(t *testing.T) {
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to a test Postgres db
+	db, err = rebuildDatabasePostgres(testPostgresDbUser, testPostgresDbName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a table with a serial key
+	_, err = db.Exec(`
+		CREATE TABLE serial_table(id SERIAL PRIMARY KEY, name VARCHAR(50));
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Let's load the fixture, rows without a pk are always inserted
+	result := new(Result)
+	err = Load([]byte(`
+- table: 'serial_table'
+  fields:
+    name: 'foo'
+- table: 'serial_table'
+  fields:
+    name: 'bar'
+`), db, "postgres", RecordResult(result))
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[int]map[string]interface{}{
+		1: {"id": int64(1)},
+		2: {"id": int64(2)},
+	}, result.Keys)
+}
+
+func TestLoadInlinesSQLExpressionsPostgres(t *testing.T) {
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to a test Postgres db
+	db, err = rebuildDatabasePostgres(testPostgresDbUser, testPostgresDbName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE events(id INT PRIMARY KEY, name VARCHAR(50), starts_at TIMESTAMP, code VARCHAR(50))
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Let's load the fixture twice, inserting and then updating the row, so
+	// placeholders after the expressions must be numbered correctly
+	data := []byte(`
+- table: 'events'
+  pk:
+    id: 1
+  fields:
+    starts_at: !sql "now() - interval '3 days'"
+    code: !sql upper('abc')
+    name: 'Launch'
+`)
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, Load(data, db, "postgres"))
+	}
+
+	var name, code string
+	var days float64
+	err = db.QueryRow(`
+		SELECT name, code, EXTRACT(EPOCH FROM now() - starts_at) / 86400 FROM events WHERE id = 1
+	`).Scan(&name, &code, &days)
+	assert.Nil(t, err)
+	assert.Equal(t, "Launch", name)
+	assert.Equal(t, "ABC", code)
+	assert.InDelta(t, 3, days, 0.01)
+}
+
+func TestLoadFillsRequiredColumnsPostgres(t *testing.T) {
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to a test Postgres db
+	db, err = rebuildDatabasePostgres(testPostgresDbUser, testPostgresDbName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE accounts(
+			id INT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+			name VARCHAR(50) NOT NULL,
+			balance NUMERIC(10, 2) NOT NULL,
+			active BOOLEAN NOT NULL,
+			opened_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			settings JSONB NOT NULL,
+			tags TEXT[] NOT NULL,
+			region VARCHAR(10) NOT NULL DEFAULT 'eu',
+			note TEXT
+		)
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Let's load a fixture omitting the required columns
+	var logged []string
+	logf := func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+	err = Load([]byte(`
+- table: 'accounts'
+  fields:
+    name: 'Alice'
+`), db, "postgres", FillRequired(logf))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"Auto-filled required columns of accounts, add them to the fixture: active, balance, opened_at, settings, tags",
+	}, logged)
+
+	var (
+		balance  float64
+		active   bool
+		openedAt time.Time
+		settings string
+		region   string
+	)
+	err = db.QueryRow(`
+		SELECT balance, active, opened_at, settings::text, region FROM accounts WHERE name = 'Alice'
+	`).Scan(&balance, &active, &openedAt, &settings, &region)
+	assert.Nil(t, err)
+	assert.Equal(t, 0.0, balance)
+	assert.False(t, active)
+	assert.True(t, openedAt.Equal(time.Unix(0, 0)))
+	assert.Equal(t, "{}", settings)
+	assert.Equal(t, "eu", region)
+}
@@ -2,9 +2,12 @@ package fixtures
 
 import (
 	"database/sql"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"testing"
+	"text/template"
 	"time"
 
 	"github.com/stretchr/testify/assert"
@@ -12,7 +15,10 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-var testSQLiteDb = "/tmp/fixtures_testdb.sqlite"
+var (
+	testSQLiteDb        = "/tmp/fixtures_testdb.sqlite"
+	testSQLiteBillingDb = "/tmp/fixtures_testdb_billing.sqlite"
+)
 
 func TestLoadWorksWithValidDataSQLite(t *testing.T) {
 	// Delete the test database
@@ -491,3 +497,1062 @@ func TestLoadFilesFailsWithABadFileSQLite(t *testing.T) {
 	// Error should be nil
 	assert.EqualError(t, err, "Error loading file bad_file: open bad_file: no such file or directory")
 }
+
+func TestLoadWorksWithSchemaQualifiedTablesSQLite(t *testing.T) {
+	// Delete the test databases
+	os.Remove(testSQLiteDb)
+	os.Remove(testSQLiteBillingDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database, attached databases only exist
+	// on the connection they are attached to
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// Create a test schema, with an attached database acting as a schema
+	_, err = db.Exec(testSchemaSQLite)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = db.Exec(fmt.Sprintf(`ATTACH DATABASE '%s' AS billing`, testSQLiteBillingDb))
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE billing.invoices(id INT PRIMARY KEY NOT NULL, amount INT NOT NULL)`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Let's load the fixture, since the database is empty, this should run inserts
+	err = Load([]byte(testSchemaQualifiedData), db, "sqlite")
+	assert.Nil(t, err)
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM billing.invoices").Scan(&count)
+	assert.Equal(t, 3, count)
+	db.QueryRow("SELECT SUM(amount) FROM billing.invoices").Scan(&count)
+	assert.Equal(t, 600, count)
+	db.QueryRow("SELECT COUNT(*) FROM main.some_table").Scan(&count)
+	assert.Equal(t, 1, count)
+
+	// Let's reload the fixture, this should run updates
+	err = Load([]byte(testSchemaQualifiedData), db, "sqlite")
+	assert.Nil(t, err)
+	db.QueryRow("SELECT COUNT(*) FROM billing.invoices").Scan(&count)
+	assert.Equal(t, 3, count)
+
+	// Verification and dumps work with qualified names too
+	err = Verify([]byte(testSchemaQualifiedData), db, "sqlite", Strict())
+	assert.Nil(t, err)
+	data, err := Dump(db, "sqlite", "billing.invoices")
+	assert.Nil(t, err)
+	assert.Equal(t, `- table: billing.invoices
+  pk:
+    id: 1
+  fields:
+    amount: 100
+- table: billing.invoices
+  pk:
+    id: 2
+  fields:
+    amount: 200
+- table: billing.invoices
+  pk:
+    id: 3
+  fields:
+    amount: 300
+`, string(data))
+
+	// Mappings must list their rows under a rows key
+	err = Load([]byte(`
+table: 'some_table'
+pk:
+  id: 1
+`), db, "sqlite")
+	assert.EqualError(t, err, "Unknown fixture keys pk, table, expected schema, defaults or rows")
+	err = Load([]byte(`
+schema: 'billing'
+`), db, "sqlite")
+	assert.EqualError(t, err, "Fixture has no rows key, rows must be a list")
+}
+
+func TestLoadResetsSequencesSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create an AUTOINCREMENT table whose sequence is ahead of its rows
+	_, err = db.Exec(`
+		CREATE TABLE auto_table(user_id INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(50));
+		INSERT INTO auto_table(user_id, name) VALUES(10, 'deleted');
+		DELETE FROM auto_table;
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Let's load the fixture
+	err = Load([]byte(`
+- table: 'auto_table'
+  pk:
+    user_id: 1
+  fields:
+    name: 'foo'
+- table: 'auto_table'
+  pk:
+    user_id: 2
+  fields:
+    name: 'bar'
+`), db, "sqlite")
+	assert.Nil(t, err)
+
+	// The next generated key follows the fixture rows
+	var seq int
+	db.QueryRow("SELECT seq FROM sqlite_sequence WHERE name = 'auto_table'").Scan(&seq)
+	assert.Equal(t, 2, seq)
+	result, err := db.Exec("INSERT INTO auto_table(name) VALUES('baz')")
+	assert.Nil(t, err)
+	id, err := result.LastInsertId()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), id)
+}
+
+func TestLoadCapturesGeneratedKeysSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a table with a generated key and one with a text key
+	_, err = db.Exec(`
+		CREATE TABLE auto_table(user_id INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(50));
+		CREATE TABLE code_table(code VARCHAR(10) PRIMARY KEY DEFAULT 'default', name VARCHAR(50));
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Let's load the fixture, rows without a pk are always inserted
+	data := []byte(`
+- table: 'auto_table'
+  pk:
+    user_id: 5
+  fields:
+    name: 'foo'
+- table: 'auto_table'
+  fields:
+    name: 'bar'
+- table: 'code_table'
+- table: 'auto_table'
+  fields:
+    name: 'bar'
+`)
+	result := new(Result)
+	err = Load(data, db, "sqlite", RecordResult(result))
+	assert.Nil(t, err)
+
+	assert.Equal(t, 4, result.Rows)
+	assert.Equal(t, map[int]map[string]interface{}{
+		2: {"user_id": int64(6)},
+		3: {"code": []byte("default")},
+		4: {"user_id": int64(7)},
+	}, result.Keys)
+
+	// Rows without a pk are matched by their fields
+	assert.Nil(t, Verify(data, db, "sqlite", Strict()))
+
+	// Positions continue across loads sharing the result
+	err = Load([]byte(`
+- table: 'auto_table'
+  fields:
+    name: 'baz'
+`), db, "sqlite", RecordResult(result))
+	assert.Nil(t, err)
+	key, ok := result.Key(5)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"user_id": int64(8)}, key)
+
+	// Loading rows without a pk again inserts them again
+	err = Load([]byte(`
+- table: 'auto_table'
+  fields:
+    name: 'bar'
+- table: 'auto_table'
+  fields:
+    name: 'bar'
+`), db, "sqlite")
+	assert.Nil(t, err)
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM auto_table WHERE name = 'bar'").Scan(&count)
+	assert.Equal(t, 4, count)
+}
+
+func TestLoadUpsertsRowsByMatchColumnsSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a table with a surrogate key and an existing row
+	_, err = db.Exec(`
+		CREATE TABLE users(id INTEGER PRIMARY KEY AUTOINCREMENT, email VARCHAR(50) UNIQUE, name VARCHAR(50));
+		INSERT INTO users(id, email, name) VALUES(7, 'alice@example.com', 'Old Alice');
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Let's load the fixture, alice is updated and bob inserted
+	data := []byte(`
+- table: 'users'
+  match:
+    email: 'alice@example.com'
+  fields:
+    name: 'Alice'
+- table: 'users'
+  match:
+    email: 'bob@example.com'
+  fields:
+    name: 'Bob'
+`)
+	result := new(Result)
+	undo := new(UndoLog)
+	err = Load(data, db, "sqlite", RecordResult(result), RecordUndo(undo))
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[int]map[string]interface{}{
+		1: {"id": int64(7)},
+		2: {"id": int64(8)},
+	}, result.Keys)
+	assert.Nil(t, Verify(data, db, "sqlite", Strict()))
+
+	// Loading the fixture again finds both rows
+	assert.Nil(t, Load(data, db, "sqlite"))
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+	assert.Equal(t, 2, count)
+
+	// Undoing the first load restores alice and deletes bob
+	assert.Nil(t, Undo(undo, db, "sqlite"))
+	var name string
+	db.QueryRow("SELECT name FROM users WHERE id = 7").Scan(&name)
+	assert.Equal(t, "Old Alice", name)
+	db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+	assert.Equal(t, 1, count)
+}
+
+func TestLoadFilesResolvesReferencesSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create tables with generated keys
+	_, err = db.Exec(`
+		CREATE TABLE authors(id INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(50));
+		CREATE TABLE posts(id INTEGER PRIMARY KEY AUTOINCREMENT, author_id INT, title VARCHAR(50));
+		INSERT INTO authors(id, name) VALUES(10, 'Bob');
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Let's load the fixture files, the second referencing the first
+	result := new(Result)
+	err = LoadFiles([]string{
+		"fixtures/test_labels1.yml",
+		"fixtures/test_labels2.yml",
+	}, db, "sqlite", RecordResult(result))
+	assert.Nil(t, err)
+
+	var title string
+	var authorID int
+	db.QueryRow("SELECT author_id, title FROM posts WHERE id = 1").Scan(&authorID, &title)
+	assert.Equal(t, 11, authorID)
+	assert.Equal(t, "Hello", title)
+	db.QueryRow("SELECT author_id, title FROM posts WHERE id = 2").Scan(&authorID, &title)
+	assert.Equal(t, 10, authorID)
+	assert.Equal(t, "Hello", title)
+
+	alice, ok := result.Label("authors", "alice")
+	assert.True(t, ok)
+	assert.Equal(t, int64(11), alice["id"])
+
+	// References are also resolved when verifying
+	data, err := ioutil.ReadFile("fixtures/test_labels1.yml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	assert.Nil(t, Verify(append(data, []byte(`
+- table: 'posts'
+  fields:
+    author_id: !ref authors.alice.id
+    title: 'Hello'
+`)...), db, "sqlite"))
+
+	// Unknown references fail with a clear error
+	err = Load([]byte(`
+- table: 'posts'
+  fields:
+    author_id: !ref authors.carol.id
+`), db, "sqlite")
+	assert.EqualError(
+		t,
+		err,
+		"Error loading row 1: Unknown reference authors.carol.id: no authors row labelled carol has been loaded before it",
+	)
+
+	// So do invalid references and duplicate labels
+	err = Load([]byte(`
+- table: 'posts'
+  fields:
+    author_id: !ref alice.id
+`), db, "sqlite")
+	assert.EqualError(t, err, `Error loading row 1: Invalid reference "alice.id", expected table.label.column`)
+	err = Load([]byte(`
+- table: 'authors'
+  label: 'carol'
+- table: 'authors'
+  label: 'carol'
+`), db, "sqlite")
+	assert.EqualError(t, err, "Error loading row 2: Duplicate label authors.carol")
+}
+
+func TestLoadDerivesKeysFromLabelsSQLite(t *testing.T) {
+	const testNamespace = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create tables with integer and UUID keys
+	_, err = db.Exec(`
+		CREATE TABLE authors(id INTEGER PRIMARY KEY, name VARCHAR(50));
+		CREATE TABLE posts(uuid VARCHAR(36) PRIMARY KEY, author_id INT, title VARCHAR(50));
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Let's load the fixture twice, keys derived from labels stay the same
+	authors := []byte(`
+- table: 'authors'
+  label: 'alice'
+  fields:
+    name: 'Alice'
+`)
+	posts := []byte(`
+- table: 'posts'
+  label: 'hello'
+  fields:
+    author_id: !ref authors.alice.id
+    title: 'Hello'
+`)
+	for i := 0; i < 2; i++ {
+		result := new(Result)
+		assert.Nil(t, Load(authors, db, "sqlite", RecordResult(result), LabelIDs(1, 1000000)))
+		assert.Nil(t, Load(posts, db, "sqlite", RecordResult(result), LabelUUIDs(testNamespace)))
+	}
+
+	id, _ := newOptions([]Option{LabelIDs(1, 1000000)}).labelKey("authors", "alice")
+	postUUID, _ := newOptions([]Option{LabelUUIDs(testNamespace)}).labelKey("posts", "hello")
+	var count, authorID int64
+	var uuid string
+	db.QueryRow("SELECT COUNT(*), MAX(id) FROM authors").Scan(&count, &authorID)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, id, authorID)
+	db.QueryRow("SELECT COUNT(*), MAX(uuid), MAX(author_id) FROM posts").Scan(&count, &uuid, &authorID)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, postUUID, uuid)
+	assert.Equal(t, id, authorID)
+
+	// Verification derives the same keys
+	assert.Nil(t, Verify(authors, db, "sqlite", LabelIDs(1, 1000000), Strict()))
+
+	// Labels deriving the same key are an error, in a load or across loads
+	bob := []byte(`
+- table: 'authors'
+  label: 'bob'
+  fields:
+    name: 'Bob'
+`)
+	err = Load(append(append([]byte{}, authors...), bob...), db, "sqlite", LabelIDs(1, 1))
+	assert.EqualError(t, err, "Error loading row 2: Labels authors.alice and authors.bob derive the same key 1")
+	result := new(Result)
+	assert.Nil(t, Load(authors, db, "sqlite", RecordResult(result), LabelIDs(2, 2)))
+	err = Load(bob, db, "sqlite", RecordResult(result), LabelIDs(2, 2))
+	assert.EqualError(t, err, "Error loading row 1: Labels authors.alice and authors.bob derive the same key 2")
+}
+
+func TestLoadFileExpandsTemplatesSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE users(id INT PRIMARY KEY, name VARCHAR(50))`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Let's load the templated fixture file
+	err = LoadFile("fixtures/test_template.yml", db, "sqlite", Template(
+		map[string]int{"Count": 50},
+		template.FuncMap{"prefix": func() string { return "user" }},
+	))
+	assert.Nil(t, err)
+
+	var count int
+	var name string
+	db.QueryRow("SELECT COUNT(*), MAX(name) FROM users").Scan(&count, &name)
+	assert.Equal(t, 50, count)
+	assert.Equal(t, "user_9", name)
+
+	// Template errors name the file
+	err = LoadFile("fixtures/test_template.yml", db, "sqlite", Template(map[string]int{"Count": 1}, nil))
+	assert.EqualError(t, err, `template: fixtures/test_template.yml:7: function "prefix" not defined`)
+
+	// Generated rows use their own delimiters in templated fixtures
+	err = Load([]byte(`
+- table: 'users'
+  count: {{ .Count }}
+  pk:
+    id: '[[add 100 .N]]'
+  fields:
+    name: '{{ prefix }}_[[.N]]'
+`), db, "sqlite", Template(
+		map[string]int{"Count": 3},
+		template.FuncMap{"prefix": func() string { return "generated" }},
+	))
+	assert.Nil(t, err)
+	db.QueryRow("SELECT name FROM users WHERE id = 103").Scan(&name)
+	assert.Equal(t, "generated_3", name)
+}
+
+func TestLoadFileDecodesTagsSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE users(
+			id INT PRIMARY KEY,
+			created_at DATETIME,
+			expires_at DATETIME,
+			name VARCHAR(50),
+			avatar BLOB,
+			api_key VARCHAR(50),
+			settings TEXT
+		)
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Setenv("FIXTURES_TEST_API_KEY", "secret")
+	defer os.Unsetenv("FIXTURES_TEST_API_KEY")
+
+	// Let's load the fixture file twice, inserting and then updating the row
+	for i := 0; i < 2; i++ {
+		err = LoadFile("fixtures/test_tags.yml", db, "sqlite")
+		assert.Nil(t, err)
+	}
+
+	var (
+		createdAt time.Time
+		days      float64
+		name      string
+		avatar    []byte
+		apiKey    string
+		settings  string
+	)
+	err = db.QueryRow(`
+		SELECT created_at, julianday(expires_at) - julianday('now'), name, avatar, api_key, settings
+		FROM users WHERE id = 1
+	`).Scan(&createdAt, &days, &name, &avatar, &apiKey, &settings)
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now(), createdAt, time.Minute)
+	assert.InDelta(t, 3, days, 0.01)
+	assert.Equal(t, "ALICE", name)
+	assert.Equal(t, []byte("avatar bytes\n"), avatar)
+	assert.Equal(t, "secret", apiKey)
+	assert.Equal(t, `{"theme": "dark"}`, settings)
+
+	assert.Nil(t, VerifyFile("fixtures/test_tags.yml", db, "sqlite"))
+
+	// Invalid tagged values are reported
+	err = Load([]byte(`
+- table: 'users'
+  pk:
+    id: 2
+  fields:
+    settings: !json {theme
+`), db, "sqlite")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Error loading row 1: Invalid !json value")
+
+	// Environment variables are stored as they are
+	os.Setenv("FIXTURES_TEST_API_KEY", "NOW+1d")
+	err = Load([]byte(`
+- table: 'users'
+  pk:
+    id: 3
+  fields:
+    api_key: !env FIXTURES_TEST_API_KEY
+`), db, "sqlite")
+	assert.Nil(t, err)
+	err = db.QueryRow(`SELECT api_key FROM users WHERE id = 3`).Scan(&apiKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "NOW+1d", apiKey)
+}
+
+func TestLoadComputesRelativeTimesSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE tokens(id INT PRIMARY KEY, expires_at DATETIME)`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Let's load the fixture, every row uses the same reference time
+	data := []byte(`
+- table: 'tokens'
+  pk:
+    id: 1
+  fields:
+    expires_at: 'NOW-3d'
+- table: 'tokens'
+  pk:
+    id: 2
+  fields:
+    expires_at: 'NOW-3d'
+- table: 'tokens'
+  pk:
+    id: 3
+  fields:
+    expires_at: 'TODAY+1d@09:00'
+`)
+	assert.Nil(t, Load(data, db, "sqlite"))
+
+	var first, second, third time.Time
+	db.QueryRow("SELECT expires_at FROM tokens WHERE id = 1").Scan(&first)
+	db.QueryRow("SELECT expires_at FROM tokens WHERE id = 2").Scan(&second)
+	db.QueryRow("SELECT expires_at FROM tokens WHERE id = 3").Scan(&third)
+	assert.Equal(t, first, second)
+	assert.WithinDuration(t, time.Now().AddDate(0, 0, -3), first, time.Minute)
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1)
+	assert.Equal(t, time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 9, 0, 0, 0, time.UTC), third.UTC())
+
+	// Verification computes them again, within the time tolerance
+	assert.Nil(t, Verify(data, db, "sqlite"))
+
+	// The bare words are text
+	_, err = db.Exec(`CREATE TABLE notes(id INT PRIMARY KEY, note TEXT)`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	assert.Nil(t, Load([]byte(`
+- table: 'notes'
+  pk:
+    id: 1
+  fields:
+    note: NOW
+`), db, "sqlite"))
+	var note string
+	db.QueryRow("SELECT note FROM notes WHERE id = 1").Scan(&note)
+	assert.Equal(t, "NOW", note)
+}
+
+func TestLoadUsesClockSQLite(t *testing.T) {
+	frozen := time.Date(2016, 3, 10, 15, 30, 45, 0, time.UTC)
+	clock := Clock(func() time.Time { return frozen })
+	data := []byte(`
+- table: 'events'
+  pk:
+    id: 1
+  fields:
+    created_at: 'ON_INSERT_NOW()'
+    updated_at: 'ON_UPDATE_NOW()'
+    starts_at: 'NOW-3d'
+    title: 'Event on {{ now | date "2006-01-02" }}'
+- table: 'events'
+  pk:
+    id: 2
+  fields:
+    created_at: !now
+    starts_at: 'TODAY+1d@09:00'
+`)
+
+	// Loading the fixture into two databases gives identical state
+	var dumps [][]byte
+	for i := 0; i < 2; i++ {
+		// Delete the test database
+		os.Remove(testSQLiteDb)
+
+		db, err := sql.Open("sqlite3", testSQLiteDb)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		_, err = db.Exec(`
+			CREATE TABLE events(
+				id INT PRIMARY KEY,
+				created_at DATETIME,
+				updated_at DATETIME,
+				starts_at DATETIME,
+				title VARCHAR(50)
+			)
+		`)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		assert.Nil(t, Load(data, db, "sqlite", clock, Template(nil, nil)))
+		dump, err := Dump(db, "sqlite", "events")
+		assert.Nil(t, err)
+		dumps = append(dumps, dump)
+
+		// Timestamps are exactly the frozen time
+		var createdAt, startsAt time.Time
+		var title string
+		db.QueryRow("SELECT created_at, starts_at, title FROM events WHERE id = 1").Scan(&createdAt, &startsAt, &title)
+		assert.True(t, frozen.Equal(createdAt))
+		assert.True(t, frozen.AddDate(0, 0, -3).Equal(startsAt))
+		assert.Equal(t, "Event on 2016-03-10", title)
+		db.QueryRow("SELECT created_at, starts_at FROM events WHERE id = 2").Scan(&createdAt, &startsAt)
+		assert.True(t, frozen.Equal(createdAt))
+		assert.True(t, time.Date(2016, 3, 11, 9, 0, 0, 0, time.UTC).Equal(startsAt))
+
+		// Verification with the same clock needs no tolerance
+		assert.Nil(t, Verify(data, db, "sqlite", clock, Template(nil, nil), TimeTolerance(0)))
+	}
+	assert.Equal(t, string(dumps[0]), string(dumps[1]))
+}
+
+func TestLoadFilesMergesDefaultsAndBaseRowsSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE users(id INT PRIMARY KEY, name VARCHAR(50), role VARCHAR(50), active BOOL)
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Let's load the fixture files, the second using defaults and labels of
+	// the first
+	err = LoadFiles([]string{
+		"fixtures/test_defaults1.yml",
+		"fixtures/test_defaults2.yml",
+	}, db, "sqlite")
+	assert.Nil(t, err)
+
+	type user struct {
+		name   string
+		role   string
+		active bool
+	}
+	expected := map[int]user{
+		1: {"Alice", "admin", true},
+		2: {"Bob", "member", true},
+		3: {"Carol", "admin", false},
+	}
+	for id, expectedUser := range expected {
+		var actual user
+		err = db.QueryRow("SELECT name, role, active FROM users WHERE id = ?", id).
+			Scan(&actual.name, &actual.role, &actual.active)
+		assert.Nil(t, err)
+		assert.Equal(t, expectedUser, actual)
+	}
+
+	// Defaults also apply when verifying
+	assert.Nil(t, VerifyFile("fixtures/test_defaults1.yml", db, "sqlite"))
+
+	// Extending an unknown row fails with a clear error
+	err = Load([]byte(`
+- table: 'users'
+  extends: 'dave'
+  pk:
+    id: 4
+`), db, "sqlite")
+	assert.EqualError(t, err, "Error loading row 1: Unknown base row users.dave: no users row labelled dave has been loaded before it")
+}
+
+func TestLoadFileExpandsGeneratorsSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE users(id INT PRIMARY KEY, email VARCHAR(50), admin BOOL);
+		CREATE TABLE posts(id INT PRIMARY KEY, author_id INT, title VARCHAR(50));
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Let's load the fixture file with generated rows
+	result := new(Result)
+	err = LoadFile("fixtures/test_generators.yml", db, "sqlite", RecordResult(result))
+	assert.Nil(t, err)
+	assert.Equal(t, 6, result.Rows)
+
+	for n := 1; n <= 4; n++ {
+		var (
+			email string
+			admin bool
+		)
+		err = db.QueryRow("SELECT email, admin FROM users WHERE id = ?", 100+n).Scan(&email, &admin)
+		assert.Nil(t, err)
+		assert.Equal(t, fmt.Sprintf("user%d@example.com", n), email)
+		assert.Equal(t, n == 1, admin)
+	}
+
+	var authorID int
+	err = db.QueryRow("SELECT author_id FROM posts WHERE id = 2").Scan(&authorID)
+	assert.Nil(t, err)
+	assert.Equal(t, 104, authorID)
+
+	// Generated rows also verify
+	assert.Nil(t, VerifyFile("fixtures/test_generators.yml", db, "sqlite"))
+
+	// Template errors report the row of the generator
+	err = Load([]byte(`
+- table: 'users'
+  count: 2
+  pk:
+    id: '{{.M}}'
+`), db, "sqlite")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Error loading row 1: Error generating id")
+}
+
+func TestLoadGeneratesFakeDataSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE users(id INTEGER PRIMARY KEY, name VARCHAR(50), email VARCHAR(100), iban VARCHAR(34))
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fixture := []byte(`
+- table: 'users'
+  pk:
+    id: 1
+  fields:
+    name: 'FAKE(name)'
+    email: 'FAKE(email)'
+    iban: 'FAKE(iban, de)'
+
+- table: 'users'
+  fields:
+    name: 'FAKE(name)'
+    email: 'FAKE(email)'
+    iban: 'FAKE(iban)'
+`)
+
+	selectUsers := func() []string {
+		rows, err := db.Query("SELECT name || ' ' || email || ' ' || iban FROM users ORDER BY id")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer rows.Close()
+		var users []string
+		for rows.Next() {
+			var user string
+			assert.Nil(t, rows.Scan(&user))
+			users = append(users, user)
+		}
+		return users
+	}
+
+	// Let's load the fixture
+	err = Load(fixture, db, "sqlite", FakeSeed(42))
+	assert.Nil(t, err)
+	users := selectUsers()
+	assert.Equal(t, 2, len(users))
+
+	// The fake data verifies with the same seed, but not with another
+	assert.Nil(t, Verify(fixture, db, "sqlite", FakeSeed(42)))
+	assert.Error(t, Verify(fixture, db, "sqlite", FakeSeed(43)))
+
+	// Reloading with the same seed produces identical data
+	_, err = db.Exec("DELETE FROM users")
+	assert.Nil(t, err)
+	err = Load(fixture, db, "sqlite", FakeSeed(42))
+	assert.Nil(t, err)
+	assert.Equal(t, users, selectUsers())
+}
+
+func TestLoadFillsRequiredColumnsSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE users(
+			id INTEGER PRIMARY KEY,
+			name VARCHAR(50) NOT NULL,
+			age INT NOT NULL,
+			admin BOOL NOT NULL,
+			role VARCHAR(50) NOT NULL DEFAULT 'member',
+			note TEXT
+		)
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fixture := []byte(`
+- table: 'users'
+  pk:
+    id: 1
+  fields:
+    age: 30
+
+- table: 'users'
+  fields:
+    name: 'Bob'
+`)
+
+	// Without the option the fixture breaks on the new columns
+	err = Load(fixture, db, "sqlite")
+	assert.Error(t, err)
+
+	// Let's load the fixture filling the required columns
+	var logged []string
+	logf := func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+	err = Load(fixture, db, "sqlite", FillRequired(logf))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"Auto-filled required columns of users, add them to the fixture: admin, age, name",
+	}, logged)
+
+	var (
+		name  string
+		age   int
+		admin bool
+		role  string
+	)
+	err = db.QueryRow("SELECT name, age, admin, role FROM users WHERE id = 1").Scan(&name, &age, &admin, &role)
+	assert.Nil(t, err)
+	assert.Equal(t, "", name)
+	assert.Equal(t, 30, age)
+	assert.False(t, admin)
+	assert.Equal(t, "member", role)
+
+	// Updates leave the columns alone, and verification ignores them
+	_, err = db.Exec("UPDATE users SET name = 'Alice' WHERE id = 1")
+	assert.Nil(t, err)
+	logged = nil
+	err = Load([]byte(`
+- table: 'users'
+  pk:
+    id: 1
+  fields:
+    age: 30
+`), db, "sqlite", FillRequired(logf))
+	assert.Nil(t, err)
+	assert.Nil(t, logged)
+	err = db.QueryRow("SELECT name FROM users WHERE id = 1").Scan(&name)
+	assert.Nil(t, err)
+	assert.Equal(t, "Alice", name)
+	assert.Nil(t, Verify(fixture, db, "sqlite"))
+}
+
+func TestLoadFilesUsesOneReferenceTimeSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE events(id INT PRIMARY KEY, created_at DATETIME)`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Two files, and a clock moving on every call
+	var filenames []string
+	for id := 1; id <= 2; id++ {
+		file, err := ioutil.TempFile("", "fixtures")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.Remove(file.Name())
+		fmt.Fprintf(file, "- table: 'events'\n  pk:\n    id: %d\n  fields:\n    created_at: 'NOW()'\n", id)
+		file.Close()
+		filenames = append(filenames, file.Name())
+	}
+	ticks := 0
+	clock := Clock(func() time.Time {
+		ticks++
+		return time.Date(2016, 3, 10, 15, 30, ticks, 0, time.UTC)
+	})
+
+	// Let's load the files, every row uses the same reference time
+	err = LoadFiles(filenames, db, "sqlite", clock)
+	assert.Nil(t, err)
+
+	var first, second time.Time
+	db.QueryRow("SELECT created_at FROM events WHERE id = 1").Scan(&first)
+	db.QueryRow("SELECT created_at FROM events WHERE id = 2").Scan(&second)
+	assert.Equal(t, time.Date(2016, 3, 10, 15, 30, 1, 0, time.UTC), first.UTC())
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, ticks)
+}
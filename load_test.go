@@ -1,5 +1,11 @@
 package fixtures
 
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
 var testSchemaSQLite = `
 CREATE TABLE some_table(
   id INT PRIMARY KEY NOT NULL,
@@ -90,6 +96,34 @@ var testData = `
     updated_at: 'ON_UPDATE_NOW()'
 `
 
+var testSchemaQualifiedData = `
+---
+schema: 'billing'
+rows:
+  - table: 'invoices'
+    pk:
+      id: 1
+    fields:
+      amount: 100
+  - table: 'billing.invoices'
+    pk:
+      id: 2
+    fields:
+      amount: 200
+  - table: 'invoices'
+    schema: 'billing'
+    pk:
+      id: 3
+    fields:
+      amount: 300
+  - table: 'main.some_table'
+    pk:
+      id: 1
+    fields:
+      string_field: 'foobar'
+      boolean_field: true
+`
+
 var (
 	fixtureFile  = "fixtures/test_fixtures1.yml"
 	fixtureFiles = []string{
@@ -97,3 +131,50 @@ var (
 		"fixtures/test_fixtures2.yml",
 	}
 )
+
+// fakeTB records test failures instead of failing the running test
+type fakeTB struct {
+	testing.TB
+	failed   bool
+	message  string
+	cleanups []func()
+}
+
+func (tb *fakeTB) Helper() {}
+
+func (tb *fakeTB) Cleanup(f func()) {
+	tb.cleanups = append(tb.cleanups, f)
+}
+
+func (tb *fakeTB) Errorf(format string, args ...interface{}) {
+	tb.failed = true
+	tb.message = fmt.Sprintf(format, args...)
+}
+
+func (tb *fakeTB) Fatal(args ...interface{}) {
+	tb.failed = true
+	tb.message = fmt.Sprint(args...)
+	runtime.Goexit()
+}
+
+func (tb *fakeTB) Fatalf(format string, args ...interface{}) {
+	tb.failed = true
+	tb.message = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// runFakeTB runs f with a fakeTB in a new goroutine, so Fatal can stop it,
+// and then runs the registered cleanup functions
+func runFakeTB(f func(tb *fakeTB)) *fakeTB {
+	tb := new(fakeTB)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(tb)
+	}()
+	<-done
+	for i := len(tb.cleanups) - 1; i >= 0; i-- {
+		tb.cleanups[i]()
+	}
+	return tb
+}
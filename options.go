@@ -0,0 +1,151 @@
+package fixtures
+
+import (
+	"strings"
+	"time"
+)
+
+// defaultTimeTolerance is how far ON_INSERT_NOW() and ON_UPDATE_NOW()
+// timestamps may be from the current time when verifying a fixture
+const defaultTimeTolerance = time.Minute
+
+// Option configures loading and verifying fixtures
+type Option func(*options)
+
+// options holds the configuration built from a list of Option values
+type options struct {
+	strict        bool
+	ignoreColumns map[string]bool
+	timeTolerance time.Duration
+	update        bool
+	undo          *UndoLog
+	result        *Result
+	labelKey      func(table, label string) (interface{}, error)
+	template      *templateOptions
+	clock         func() time.Time
+	fakeSeed      int64
+	fakeLocale    string
+	sequences     map[string]int64
+	fillRequired  bool
+	fillLog       func(format string, args ...interface{})
+}
+
+// newOptions applies a list of options on top of the defaults
+func newOptions(opts []Option) *options {
+	o := &options{
+		ignoreColumns: make(map[string]bool),
+		timeTolerance: defaultTimeTolerance,
+		clock:         time.Now,
+		fakeLocale:    defaultLocale,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Strict makes verification also report rows that exist in the database but
+// are not listed in the fixture, for every table the fixture mentions
+func Strict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// IgnoreColumns excludes columns from verification. A column is either a
+// plain name such as "updated_at", matching in every table, or a qualified
+// name such as "users.last_login".
+func IgnoreColumns(columns ...string) Option {
+	return func(o *options) {
+		for _, column := range columns {
+			o.ignoreColumns[column] = true
+		}
+	}
+}
+
+// TimeTolerance sets how far ON_INSERT_NOW() and ON_UPDATE_NOW() timestamps
+// may be from the current time when verifying a fixture
+func TimeTolerance(d time.Duration) Option {
+	return func(o *options) {
+		o.timeTolerance = d
+	}
+}
+
+// Clock sets the function returning the reference time of a load, which is
+// called once per call of Load, LoadFile, LoadFiles or Use, so every file
+// they load agrees, and used for ON_INSERT_NOW(), ON_UPDATE_NOW(), NOW(),
+// !now, relative times and templates. Tests can freeze time with it to get
+// identical timestamps across runs:
+//
+//	fixtures.Clock(func() time.Time {
+//		return time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+//	})
+func Clock(clock func() time.Time) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// fixedClock sets the reference time of a load to t
+func fixedClock(t time.Time) Option {
+	return Clock(func() time.Time { return t })
+}
+
+// sharedSequences makes loads continue the SEQ() counters of sequences
+func sharedSequences(sequences map[string]int64) Option {
+	return func(o *options) {
+		o.sequences = sequences
+	}
+}
+
+// UpdateGolden makes Golden rewrite golden files from the database instead
+// of comparing them, usually wired to a test flag:
+//
+//	var update = flag.Bool("update", false, "update golden files")
+//
+//	fixtures.Golden(t, "testdata/users.golden.yml", db, "postgres",
+//		[]string{"users"}, fixtures.UpdateGolden(*update))
+func UpdateGolden(update bool) Option {
+	return func(o *options) {
+		o.update = update
+	}
+}
+
+// isIgnored returns true if the column should not be verified. Qualified
+// names may include the schema or not.
+func (o *options) isIgnored(row *Row, column string) bool {
+	return o.ignoreColumns[column] ||
+		o.ignoreColumns[strings.Join([]string{row.table, column}, ".")] ||
+		o.ignoreColumns[strings.Join([]string{row.tableName(), column}, ".")]
+}
+
+// FakeSeed sets the seed of FAKE() values, 0 by default. Loading a fixture
+// again with the same seed produces the same values.
+func FakeSeed(seed int64) Option {
+	return func(o *options) {
+		o.fakeSeed = seed
+	}
+}
+
+// FakeLocale sets the locale of FAKE() values, "en" by default, see
+// RegisterLocale
+func FakeLocale(name string) Option {
+	return func(o *options) {
+		o.fakeLocale = name
+	}
+}
+
+// FillRequired makes Load fill the NOT NULL columns without a default that a
+// fixture omits when it inserts a row, so adding such a column doesn't break
+// every fixture of the table. Columns get 0, an empty string, false, the Unix
+// epoch or an empty JSON object depending on their type, are not updated or
+// verified, and are logged once per table and load with logf, or log.Printf
+// if it is nil, so fixtures can be updated deliberately:
+//
+//	fixtures.LoadFile("fixtures/users.yml", db, "postgres", fixtures.FillRequired(t.Logf))
+func FillRequired(logf func(format string, args ...interface{})) Option {
+	return func(o *options) {
+		o.fillRequired = true
+		o.fillLog = logf
+	}
+}
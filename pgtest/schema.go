@@ -0,0 +1,59 @@
+package pgtest
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	fixtures "github.com/AreaHQ/go-fixtures"
+)
+
+// schemaCount makes the names of per-test schemas unique
+var schemaCount int64
+
+// NewSchema isolates a test, including a parallel one, in its own schema of
+// a shared Postgres database. It creates a uniquely named schema, runs ddl
+// and loads the fixture files into it, and drops it when the test finishes.
+// The returned *sql.DB has its own connections, all with search_path set to
+// the schema, so unqualified table names resolve to it. The dsn must be in
+// key/value format.
+func NewSchema(t testing.TB, dsn string, ddl func(db *sql.DB) error, filenames []string, opts ...fixtures.Option) *sql.DB {
+	t.Helper()
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Error connecting to database: %s", err)
+	}
+
+	schema := fmt.Sprintf("fixtures_%d_%d", os.Getpid(), atomic.AddInt64(&schemaCount, 1))
+	if _, err := admin.Exec(fmt.Sprintf(`CREATE SCHEMA "%s"`, schema)); err != nil {
+		admin.Close()
+		t.Fatalf("Error creating schema %s: %s", schema, err)
+	}
+
+	db, err := sql.Open("postgres", fmt.Sprintf("%s search_path='%s'", dsn, schema))
+	if err != nil {
+		admin.Close()
+		t.Fatalf("Error connecting to schema %s: %s", schema, err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		if _, err := admin.Exec(fmt.Sprintf(`DROP SCHEMA "%s" CASCADE`, schema)); err != nil {
+			t.Errorf("Error dropping schema %s: %s", schema, err)
+		}
+		admin.Close()
+	})
+
+	if ddl != nil {
+		if err := ddl(db); err != nil {
+			t.Fatalf("Error creating tables in schema %s: %s", schema, err)
+		}
+	}
+	if err := fixtures.LoadFiles(filenames, db, "postgres", opts...); err != nil {
+		t.Fatalf("Error loading fixtures into schema %s: %s", schema, err)
+	}
+
+	return db
+}
@@ -0,0 +1,73 @@
+package pgtest
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testSchemaDSN = fmt.Sprintf(
+		"sslmode=disable host=localhost port=5432 user=%s password='' dbname=postgres",
+		testPostgresDbUser,
+	)
+	testFixtureFile = "/tmp/fixtures_pgtest_fixture.yml"
+)
+
+func TestNewSchemaIsolatesParallelTestsPostgres(t *testing.T) {
+	err := ioutil.WriteFile(testFixtureFile, []byte(testData), 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(testFixtureFile)
+
+	ddl := func(db *sql.DB) error {
+		_, err := db.Exec(testSchema)
+		return err
+	}
+
+	var schemas = make(chan string, 2)
+
+	t.Run("Group", func(t *testing.T) {
+		for _, id := range []int{1, 2} {
+			id := id
+			t.Run("Delete", func(t *testing.T) {
+				t.Parallel()
+				db := NewSchema(t, testSchemaDSN, ddl, []string{testFixtureFile})
+
+				var (
+					count  int
+					schema string
+				)
+				db.QueryRow("SELECT current_schema()").Scan(&schema)
+				schemas <- schema
+
+				// Unqualified names resolve to the test's own schema
+				db.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+				assert.Equal(t, 1, count)
+				_, err := db.Exec("INSERT INTO some_table(id, string_field) VALUES($1, 'extra')", id+1)
+				assert.Nil(t, err)
+				db.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+				assert.Equal(t, 2, count)
+			})
+		}
+	})
+	close(schemas)
+
+	// The schemas have been dropped
+	admin, err := sql.Open("postgres", testSchemaDSN)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer admin.Close()
+	for schema := range schemas {
+		var count int
+		admin.QueryRow("SELECT COUNT(*) FROM pg_namespace WHERE nspname = $1", schema).Scan(&count)
+		assert.Equal(t, 0, count)
+	}
+}
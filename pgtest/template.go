@@ -0,0 +1,163 @@
+// Package pgtest provides fast, isolated Postgres databases for tests.
+//
+// A Template is built once per test binary, with the schema and fixtures
+// loaded by a setup function, and every test then gets its own copy created
+// with CREATE DATABASE ... TEMPLATE, which is much faster than rebuilding
+// the schema and reloading fixtures:
+//
+//	var tmpl *pgtest.Template
+//
+//	func TestMain(m *testing.M) {
+//		var err error
+//		tmpl, err = pgtest.NewTemplate(
+//			"sslmode=disable user=foo dbname=postgres",
+//			"myapp_template",
+//			func(db *sql.DB) error {
+//				if _, err := db.Exec(schema); err != nil {
+//					return err
+//				}
+//				return fixtures.LoadFiles(files, db, "postgres")
+//			},
+//		)
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		code := m.Run()
+//		tmpl.Close()
+//		os.Exit(code)
+//	}
+//
+//	func TestSomething(t *testing.T) {
+//		db := tmpl.NewDB(t)
+//		// ...
+//	}
+//
+// Alternatively, NewSchema isolates each test in its own schema of a shared
+// database, which suits parallel tests against a single Postgres instance.
+package pgtest
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	// Driver
+	_ "github.com/lib/pq"
+)
+
+// Template is a Postgres database used as a template for test databases
+type Template struct {
+	dsn   string
+	name  string
+	admin *sql.DB
+	mu    sync.Mutex
+	count int
+}
+
+// NewTemplate (re)creates the template database called name and runs setup
+// against it. The dsn must be in key/value format and point at a maintenance
+// database such as "postgres", its dbname is replaced when connecting to the
+// template and test databases.
+func NewTemplate(dsn, name string, setup func(db *sql.DB) error) (*Template, error) {
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := &Template{dsn: dsn, name: name, admin: admin}
+	if err := tmpl.dropDatabase(name); err != nil {
+		admin.Close()
+		return nil, err
+	}
+	if _, err := admin.Exec(fmt.Sprintf(`CREATE DATABASE "%s"`, name)); err != nil {
+		admin.Close()
+		return nil, err
+	}
+
+	// A template can not be copied while anyone is connected to it, so the
+	// setup connection is closed straight away
+	db, err := tmpl.open(name)
+	if err != nil {
+		tmpl.Close()
+		return nil, err
+	}
+	err = setup(db)
+	db.Close()
+	if err != nil {
+		tmpl.Close()
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// Name returns the name of the template database
+func (tmpl *Template) Name() string {
+	return tmpl.name
+}
+
+// Clone creates a new database called name from the template and connects
+// to it
+func (tmpl *Template) Clone(name string) (*sql.DB, error) {
+	// Postgres refuses to copy a template used by another CREATE DATABASE
+	tmpl.mu.Lock()
+	_, err := tmpl.admin.Exec(fmt.Sprintf(`CREATE DATABASE "%s" TEMPLATE "%s"`, name, tmpl.name))
+	tmpl.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl.open(name)
+}
+
+// Drop drops a database created by Clone. Connections to it must have been
+// closed.
+func (tmpl *Template) Drop(name string) error {
+	return tmpl.dropDatabase(name)
+}
+
+// NewDB clones the template into a uniquely named database for the test and
+// drops it when the test finishes
+func (tmpl *Template) NewDB(t testing.TB) *sql.DB {
+	t.Helper()
+
+	tmpl.mu.Lock()
+	tmpl.count++
+	name := fmt.Sprintf("%s_%d_%d", tmpl.name, os.Getpid(), tmpl.count)
+	tmpl.mu.Unlock()
+
+	db, err := tmpl.Clone(name)
+	if err != nil {
+		t.Fatalf("Error cloning template database %s: %s", tmpl.name, err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		if err := tmpl.Drop(name); err != nil {
+			t.Errorf("Error dropping database %s: %s", name, err)
+		}
+	})
+
+	return db
+}
+
+// Close drops the template database and closes the maintenance connection
+func (tmpl *Template) Close() error {
+	err := tmpl.dropDatabase(tmpl.name)
+	if closeErr := tmpl.admin.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// open connects to a database on the same server
+func (tmpl *Template) open(name string) (*sql.DB, error) {
+	return sql.Open("postgres", fmt.Sprintf("%s dbname='%s'", tmpl.dsn, name))
+}
+
+// dropDatabase drops a database if it exists
+func (tmpl *Template) dropDatabase(name string) error {
+	_, err := tmpl.admin.Exec(fmt.Sprintf(`DROP DATABASE IF EXISTS "%s"`, name))
+	return err
+}
@@ -0,0 +1,80 @@
+package pgtest
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"testing"
+
+	fixtures "github.com/AreaHQ/go-fixtures"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testPostgresDbUser = "go_fixtures"
+	testPostgresDSN    = fmt.Sprintf(
+		"sslmode=disable host=localhost port=5432 user=%s password='' dbname=postgres",
+		testPostgresDbUser,
+	)
+)
+
+var testSchema = `
+CREATE TABLE some_table(
+  id INT PRIMARY KEY NOT NULL,
+  string_field VARCHAR(50) NOT NULL
+);
+`
+
+var testData = `
+---
+- table: 'some_table'
+  pk:
+    id: 1
+  fields:
+    string_field: 'foobar'
+`
+
+func TestTemplateClonesDatabasesPostgres(t *testing.T) {
+	tmpl, err := NewTemplate(testPostgresDSN, "go_fixtures_template_test", func(db *sql.DB) error {
+		if _, err := db.Exec(testSchema); err != nil {
+			return err
+		}
+		return fixtures.Load([]byte(testData), db, "postgres")
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer tmpl.Close()
+
+	var (
+		count int
+		name  string
+	)
+
+	t.Run("First", func(t *testing.T) {
+		db := tmpl.NewDB(t)
+		db.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+		assert.Equal(t, 1, count)
+		db.QueryRow("SELECT current_database()").Scan(&name)
+
+		// Changes are only visible in this copy
+		_, err := db.Exec("DELETE FROM some_table")
+		assert.Nil(t, err)
+	})
+
+	// The copy has been dropped
+	tmpl.admin.QueryRow("SELECT COUNT(*) FROM pg_database WHERE datname = $1", name).Scan(&count)
+	assert.Equal(t, 0, count)
+
+	t.Run("Second", func(t *testing.T) {
+		db := tmpl.NewDB(t)
+		db.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+		assert.Equal(t, 1, count)
+	})
+
+	// Failing setups are reported
+	_, err = NewTemplate(testPostgresDSN, "go_fixtures_template_test_bad", func(db *sql.DB) error {
+		return fixtures.Load([]byte(testData), db, "postgres")
+	})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,238 @@
+package fixtures
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// labelledRow holds the known column values of a labelled row, and its key
+// to look up other columns in the database
+type labelledRow struct {
+	row    Row
+	values map[string]interface{}
+	fields map[string]interface{}
+}
+
+// labels holds labelled rows by "table.label", with schema-qualified tables
+// also listed under the bare table name
+type labels map[string]*labelledRow
+
+// add records a labelled row with the key the database holds it under
+func (l labels) add(row *Row, key map[string]interface{}) error {
+	if row.Label == "" {
+		return nil
+	}
+	if len(key) == 0 {
+		key = row.key()
+	}
+	name := row.tableName() + "." + row.Label
+	if _, ok := l[name]; ok {
+		return fmt.Errorf("Duplicate label %s", name)
+	}
+
+	labelled := &labelledRow{
+		row:    Row{Table: row.Table, Schema: row.Schema, PK: key},
+		values: make(map[string]interface{}),
+		fields: make(map[string]interface{}),
+	}
+	for column, value := range row.Fields {
+		labelled.fields[column] = value
+	}
+	labelled.row.Init()
+	for _, values := range []map[string]interface{}{row.Fields, row.Match, row.PK, key} {
+		for column, value := range values {
+			// Computed values are read from the database when referenced
+			if _, _, ok := parseValueFunc(value); ok {
+				continue
+			}
+			if _, ok := value.(SQL); ok {
+				continue
+			}
+			if _, ok, _ := parseRelativeTime(value, time.Time{}); ok {
+				continue
+			}
+			labelled.values[column] = literalValue(value)
+		}
+	}
+
+	l[name] = labelled
+	if row.schema != "" {
+		l[row.table+"."+row.Label] = labelled
+	}
+	return nil
+}
+
+// resolveRefs replaces the references in the values of a row with the
+// columns they point at, looking up labels in order
+func resolveRefs(q queryer, driver string, row *Row, all ...labels) error {
+	for _, values := range []map[string]interface{}{row.PK, row.Match, row.Fields} {
+		for column, value := range values {
+			tag, ref, ok := tagValue(value)
+			if !ok || tag != "ref" {
+				continue
+			}
+			resolved, err := resolveRef(q, driver, ref, all)
+			if err != nil {
+				return err
+			}
+			values[column] = resolved
+		}
+	}
+	return nil
+}
+
+// resolveRef returns the value of a reference in table.label.column form
+func resolveRef(q queryer, driver string, ref string, all []labels) (interface{}, error) {
+	parts := strings.Split(ref, ".")
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("Invalid reference %q, expected table.label.column", ref)
+	}
+	column := parts[len(parts)-1]
+	label := parts[len(parts)-2]
+	table := strings.Join(parts[:len(parts)-2], ".")
+
+	var labelled *labelledRow
+	for _, l := range all {
+		if labelled = l[table+"."+label]; labelled != nil {
+			break
+		}
+	}
+	if labelled == nil {
+		return nil, fmt.Errorf(
+			"Unknown reference %s: no %s row labelled %s has been loaded before it",
+			ref, table, label,
+		)
+	}
+	if value, ok := labelled.values[column]; ok {
+		return value, nil
+	}
+
+	// Columns left to their defaults are read from the database
+	if len(labelled.row.PK) == 0 {
+		return nil, fmt.Errorf("Unknown reference %s: table %s has no primary key", ref, table)
+	}
+	var value interface{}
+	selectQuery := fmt.Sprintf(
+		`SELECT %s FROM %s WHERE %s`,
+		quoteIdentifier(driver, column),
+		labelled.row.GetTable(driver),
+		labelled.row.GetWhere(driver, 0),
+	)
+	if err := q.QueryRow(selectQuery, labelled.row.GetPKValues()...).Scan(&value); err != nil {
+		return nil, fmt.Errorf("Unknown reference %s: %s", ref, err)
+	}
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	labelled.values[column] = value
+	return value, nil
+}
+
+// LabelIDs gives labelled rows without a pk or match columns a deterministic
+// integer primary key between min and max, derived from a hash of the table
+// and label, so fixtures do not pick keys by hand and keep them across
+// machines and reloads. The table must have a single primary key column.
+// The range can not cover every int64.
+func LabelIDs(min, max int64) Option {
+	return func(o *options) {
+		o.labelKey = func(table, label string) (interface{}, error) {
+			if max < min {
+				return nil, fmt.Errorf("Invalid label ID range %d-%d", min, max)
+			}
+			// The size of the range wraps around to 0 for every int64
+			size := uint64(max) - uint64(min) + 1
+			if size == 0 {
+				return nil, fmt.Errorf("Invalid label ID range %d-%d, it covers every int64", min, max)
+			}
+			h := fnv.New64a()
+			h.Write([]byte(table + "." + label))
+			return int64(uint64(min) + h.Sum64()%size), nil
+		}
+	}
+}
+
+// LabelUUIDs gives labelled rows without a pk or match columns a version 5
+// UUID primary key in the namespace UUID, derived from the table and label
+func LabelUUIDs(namespace string) Option {
+	return func(o *options) {
+		o.labelKey = func(table, label string) (interface{}, error) {
+			ns, err := parseUUID(namespace)
+			if err != nil {
+				return nil, err
+			}
+			return uuidV5(ns, table+"."+label), nil
+		}
+	}
+}
+
+// labelKeys maps the primary keys derived from labels, prefixed with their
+// table, to the labels they were derived from
+type labelKeys map[string]string
+
+// setLabelKey sets the primary key of a labelled row without one, when an
+// option derives keys from labels. Keys are recorded in derived, and two
+// labels of a table deriving the same key, in this or an earlier load, are an
+// error.
+func setLabelKey(q queryer, driver string, row *Row, o *options, derived labelKeys, earlier labelKeys) error {
+	if o.labelKey == nil || row.Label == "" || len(row.key()) > 0 {
+		return nil
+	}
+
+	table := row.Table
+	if row.Schema != "" {
+		table = row.Schema + "." + row.Table
+	}
+	pkColumns, err := primaryKeyColumns(q, driver, table)
+	if err != nil {
+		return err
+	}
+	if len(pkColumns) != 1 {
+		return fmt.Errorf("Table %s needs a single primary key column to derive it from the label", table)
+	}
+
+	key, err := o.labelKey(table, row.Label)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s.%v", table, key)
+	for _, keys := range []labelKeys{derived, earlier} {
+		if label, ok := keys[name]; ok && label != row.Label {
+			return fmt.Errorf("Labels %s.%s and %s.%s derive the same key %v", table, label, table, row.Label, key)
+		}
+	}
+	derived[name] = row.Label
+	row.PK = map[string]interface{}{pkColumns[0]: key}
+	return nil
+}
+
+// parseUUID parses a UUID in its canonical text form
+func parseUUID(s string) ([16]byte, error) {
+	var uuid [16]byte
+	b, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil || len(b) != 16 {
+		return uuid, fmt.Errorf("Invalid UUID %q", s)
+	}
+	copy(uuid[:], b)
+	return uuid, nil
+}
+
+// uuidV5 returns the name-based version 5 UUID of name in a namespace
+func uuidV5(namespace [16]byte, name string) string {
+	h := sha1.New()
+	h.Write(namespace[:])
+	h.Write([]byte(name))
+	sum := h.Sum(nil)
+
+	sum[6] = sum[6]&0x0f | 0x50 // version 5
+	sum[8] = sum[8]&0x3f | 0x80 // RFC 4122 variant
+	return formatUUID(sum[:16])
+}
+
+// formatUUID formats 16 bytes as a UUID in its canonical text form
+func formatUUID(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,42 @@
+package fixtures
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelIDs(t *testing.T) {
+	o := newOptions([]Option{LabelIDs(1, 100)})
+
+	id, err := o.labelKey("users", "alice")
+	assert.Nil(t, err)
+	again, err := o.labelKey("users", "alice")
+	assert.Nil(t, err)
+	assert.Equal(t, id, again)
+	assert.True(t, id.(int64) >= 1 && id.(int64) <= 100)
+
+	_, err = newOptions([]Option{LabelIDs(10, 1)}).labelKey("users", "alice")
+	assert.EqualError(t, err, "Invalid label ID range 10-1")
+
+	// Ranges wider than the largest int64 still work, but not every int64
+	id, err = newOptions([]Option{LabelIDs(math.MinInt64, math.MaxInt64-1)}).labelKey("users", "alice")
+	assert.Nil(t, err)
+	assert.True(t, id.(int64) < math.MaxInt64)
+	id, err = newOptions([]Option{LabelIDs(math.MaxInt64, math.MaxInt64)}).labelKey("users", "alice")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(math.MaxInt64), id)
+	_, err = newOptions([]Option{LabelIDs(math.MinInt64, math.MaxInt64)}).labelKey("users", "alice")
+	assert.EqualError(t, err, "Invalid label ID range -9223372036854775808-9223372036854775807, it covers every int64")
+}
+
+func TestLabelUUIDs(t *testing.T) {
+	// The DNS namespace example from Python's uuid module
+	ns, err := parseUUID("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	assert.Nil(t, err)
+	assert.Equal(t, "886313e1-3b8a-5372-9b90-0c9aee199e5d", uuidV5(ns, "python.org"))
+
+	_, err = newOptions([]Option{LabelUUIDs("foo")}).labelKey("users", "alice")
+	assert.EqualError(t, err, `Invalid UUID "foo"`)
+}
@@ -0,0 +1,75 @@
+package fixtures
+
+// Result collects what one or more fixture loads did to the database
+type Result struct {
+	// Rows counts the fixture rows loaded so far
+	Rows int
+
+	// Keys maps the position of a row without a primary key, counting from 1
+	// across every load sharing the result, to the key the database generated
+	// for it
+	Keys map[int]map[string]interface{}
+
+	// labels holds the labelled rows, for references from later loads
+	labels labels
+
+	// labelKeys holds the keys derived from labels, to detect collisions in
+	// later loads
+	labelKeys labelKeys
+
+	// defaults holds the table defaults of the loaded fixtures
+	defaults tableDefaults
+}
+
+// RecordResult makes Load add what it commits to the result, such as the
+// keys generated for rows without a primary key
+func RecordResult(result *Result) Option {
+	return func(o *options) {
+		o.result = result
+	}
+}
+
+// Key returns the key generated for a row without a primary key, by its
+// position counting from 1 across every load sharing the result
+func (r *Result) Key(row int) (map[string]interface{}, bool) {
+	key, ok := r.Keys[row]
+	return key, ok
+}
+
+// Label returns the known column values of the row of a table with a label,
+// including generated keys
+func (r *Result) Label(table, label string) (map[string]interface{}, bool) {
+	labelled, ok := r.labels[table+"."+label]
+	if !ok {
+		return nil, false
+	}
+	return labelled.values, true
+}
+
+// add adds the rows of a committed load, with generated keys indexed by their
+// position in the load counting from 0
+func (r *Result) add(rows int, keys map[int]map[string]interface{}, loaded labels, derived labelKeys, defaults tableDefaults) {
+	if r.Keys == nil {
+		r.Keys = make(map[int]map[string]interface{})
+	}
+	if r.labels == nil {
+		r.labels = make(labels)
+	}
+	for name, labelled := range loaded {
+		r.labels[name] = labelled
+	}
+	if r.labelKeys == nil {
+		r.labelKeys = make(labelKeys)
+	}
+	for name, label := range derived {
+		r.labelKeys[name] = label
+	}
+	if r.defaults == nil {
+		r.defaults = make(tableDefaults)
+	}
+	r.defaults.merge(defaults)
+	for i, key := range keys {
+		r.Keys[r.Rows+i+1] = key
+	}
+	r.Rows += rows
+}
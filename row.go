@@ -11,13 +11,56 @@ const (
 	onInsertNow    = "ON_INSERT_NOW()"
 	onUpdateNow    = "ON_UPDATE_NOW()"
 	postgresDriver = "postgres"
+	sqliteDriver   = "sqlite"
+	sqlite3Driver  = "sqlite3"
+	mysqlDriver    = "mysql"
 )
 
-// Row represents a single database row
+// SQL marks a field value as a raw SQL expression, such as
+// SQL("now() - interval '3 days'"), which is inlined in queries instead of
+// being sent as a bind parameter. In YAML fixtures it is written with the !sql
+// tag.
+type SQL string
+
+// Literal marks a field value as text that is stored as it is, such as
+// Literal("NOW+1d"), rather than parsed as a relative time or a value
+// function. In YAML fixtures it is written with the !literal tag.
+type Literal string
+
+// MarshalYAML writes literals in the form the !literal tag is decoded to, so
+// dumped values load back as the same text
+func (l Literal) MarshalYAML() (interface{}, error) {
+	return map[string]string{"!literal": string(l)}, nil
+}
+
+// literalValue returns the text of literals and any other value as it is
+func literalValue(v interface{}) interface{} {
+	if l, ok := v.(Literal); ok {
+		return string(l)
+	}
+	return v
+}
+
+// Row represents a single database row. Table can be schema-qualified with
+// dotted syntax, such as "billing.invoices", or the schema can be given
+// separately in Schema. Rows of tables with a surrogate key can be found by
+// a unique natural key in Match instead of PK. A Label names the row for
+// references from other rows, such as `author_id: !ref users.alice.id`, and
+// for rows that inherit its fields with Extends. A Count expands the row into
+// that many rows, with {{.N}} templates in its values counting from 1.
 type Row struct {
-	Table              string
-	PK                 map[string]interface{}
-	Fields             map[string]interface{}
+	Table              string                 `yaml:"table"`
+	Schema             string                 `yaml:"schema,omitempty"`
+	Label              string                 `yaml:"label,omitempty"`
+	Extends            string                 `yaml:"extends,omitempty"`
+	Count              int                    `yaml:"count,omitempty"`
+	PK                 map[string]interface{} `yaml:"pk"`
+	Match              map[string]interface{} `yaml:"match,omitempty"`
+	Fields             map[string]interface{} `yaml:"fields,omitempty"`
+	now                time.Time
+	source             *valueSource
+	schema             string
+	table              string
 	insertColumnLength int
 	updateColumnLength int
 	pkColumns          []string
@@ -28,11 +71,27 @@ type Row struct {
 	updateValues       []interface{}
 }
 
-// Init loads internal struct variables
-func (row *Row) Init() {
+// Init loads internal struct variables, calling the value functions of
+// fields such as NOW() or SEQ(orders) and computing relative times such as
+// NOW-3d from the reference time of the load
+func (row *Row) Init() error {
+	if row.now.IsZero() {
+		row.now = time.Now()
+	}
+	if row.source == nil {
+		row.source = newValueSource(newOptions(nil))
+	}
+
+	// Table name, split into schema and table
+	row.schema, row.table = splitTable(row.Table)
+	if row.Schema != "" {
+		row.schema, row.table = row.Schema, row.Table
+	}
+
 	// Initial values
-	row.insertColumnLength = len(row.PK) + len(row.Fields)
-	row.updateColumnLength = len(row.PK) + len(row.Fields)
+	key := row.key()
+	row.insertColumnLength = len(key) + len(row.Fields)
+	row.updateColumnLength = len(key) + len(row.Fields)
 	row.pkColumns = make([]string, 0)
 	row.pkValues = make([]interface{}, 0)
 	row.insertColumns = make([]string, 0)
@@ -42,9 +101,9 @@ func (row *Row) Init() {
 
 	// Get and sort map keys
 	var i int
-	pkKeys := make([]string, len(row.PK))
+	pkKeys := make([]string, len(key))
 	i = 0
-	for pkKey := range row.PK {
+	for pkKey := range key {
 		pkKeys[i] = pkKey
 		i++
 	}
@@ -60,33 +119,70 @@ func (row *Row) Init() {
 	// Primary keys
 	for _, pkKey := range pkKeys {
 		row.pkColumns = append(row.pkColumns, pkKey)
-		row.pkValues = append(row.pkValues, row.PK[pkKey])
+		value := literalValue(key[pkKey])
+		row.pkValues = append(row.pkValues, value)
 		row.insertColumns = append(row.insertColumns, pkKey)
 		row.updateColumns = append(row.updateColumns, pkKey)
-		row.insertValues = append(row.insertValues, row.PK[pkKey])
-		row.updateValues = append(row.updateValues, row.PK[pkKey])
+		row.insertValues = append(row.insertValues, value)
+		row.updateValues = append(row.updateValues, value)
 	}
 
 	// Rest of the fields
 	for _, fieldKey := range fieldKeys {
-		sv, ok := row.Fields[fieldKey].(string)
-		if ok && sv == onInsertNow {
+		t, ok, err := parseRelativeTime(row.Fields[fieldKey], row.now)
+		if err != nil {
+			return err
+		}
+		if ok {
 			row.insertColumns = append(row.insertColumns, fieldKey)
-			row.insertValues = append(row.insertValues, time.Now())
-			row.updateColumnLength--
+			row.updateColumns = append(row.updateColumns, fieldKey)
+			row.insertValues = append(row.insertValues, t)
+			row.updateValues = append(row.updateValues, t)
 			continue
 		}
-		if ok && sv == onUpdateNow {
-			row.updateColumns = append(row.updateColumns, fieldKey)
-			row.updateValues = append(row.updateValues, time.Now())
-			row.insertColumnLength--
+
+		fn, args, ok := parseValueFunc(row.Fields[fieldKey])
+		if ok {
+			value, err := fn.Call(&ValueContext{
+				Now:    row.now,
+				Table:  row.tableName(),
+				PK:     key,
+				Column: fieldKey,
+				Locale: row.source.locale,
+				source: row.source,
+			}, args)
+			if err != nil {
+				return fmt.Errorf("Error computing %s: %s", fieldKey, err)
+			}
+			if fn.OnInsert {
+				row.insertColumns = append(row.insertColumns, fieldKey)
+				row.insertValues = append(row.insertValues, value)
+			} else {
+				row.insertColumnLength--
+			}
+			if fn.OnUpdate {
+				row.updateColumns = append(row.updateColumns, fieldKey)
+				row.updateValues = append(row.updateValues, value)
+			} else {
+				row.updateColumnLength--
+			}
 			continue
 		}
 		row.insertColumns = append(row.insertColumns, fieldKey)
 		row.updateColumns = append(row.updateColumns, fieldKey)
-		row.insertValues = append(row.insertValues, row.Fields[fieldKey])
-		row.updateValues = append(row.updateValues, row.Fields[fieldKey])
+		row.insertValues = append(row.insertValues, literalValue(row.Fields[fieldKey]))
+		row.updateValues = append(row.updateValues, literalValue(row.Fields[fieldKey]))
 	}
+
+	return nil
+}
+
+// GetTable returns the quoted, schema-qualified table name
+func (row *Row) GetTable(driver string) string {
+	if row.schema == "" {
+		return quoteIdentifier(driver, row.table)
+	}
+	return quoteIdentifier(driver, row.schema) + "." + quoteIdentifier(driver, row.table)
 }
 
 // GetInsertColumnsLength returns number of columns for INSERT query
@@ -117,58 +213,163 @@ func (row *Row) GetUpdateColumns() []string {
 	return escapedColumns
 }
 
-// GetInsertValues returns a slice of values for INSERT query
+// quotedInsertColumns returns the column names for INSERT query, quoted for
+// the driver
+func (row *Row) quotedInsertColumns(driver string) []string {
+	escapedColumns := make([]string, len(row.insertColumns))
+	for i, insertColumn := range row.insertColumns {
+		escapedColumns[i] = quoteIdentifier(driver, insertColumn)
+	}
+	return escapedColumns
+}
+
+// quotedUpdateColumns returns the column names for UPDATE query, quoted for
+// the driver
+func (row *Row) quotedUpdateColumns(driver string) []string {
+	escapedColumns := make([]string, len(row.updateColumns))
+	for i, updateColumn := range row.updateColumns {
+		escapedColumns[i] = quoteIdentifier(driver, updateColumn)
+	}
+	return escapedColumns
+}
+
+// GetInsertValues returns a slice of bind values for INSERT query, without
+// the raw SQL expressions inlined by GetInsertPlaceholders
 func (row *Row) GetInsertValues() []interface{} {
-	return row.insertValues
+	return bindValues(row.insertValues)
 }
 
-// GetUpdateValues returns a slice of values for UPDATE query
+// GetUpdateValues returns a slice of bind values for UPDATE query, without
+// the raw SQL expressions inlined by GetUpdatePlaceholders
 func (row *Row) GetUpdateValues() []interface{} {
-	return row.updateValues
+	return bindValues(row.updateValues)
 }
 
-// GetInsertPlaceholders returns a slice of placeholders for INSERT query
+// GetInsertPlaceholders returns a slice of placeholders for INSERT query, with
+// raw SQL expressions inlined
 func (row *Row) GetInsertPlaceholders(driver string) []string {
 	placeholders := make([]string, row.GetInsertColumnsLength())
+	n := 0
 	for i := 0; i < row.GetInsertColumnsLength(); i++ {
-		if driver == postgresDriver {
-			placeholders[i] = fmt.Sprintf("$%d", i+1)
-		} else {
-			placeholders[i] = "?"
-		}
+		placeholders[i] = placeholder(driver, row.insertValues[i], &n)
 	}
 	return placeholders
 }
 
-// GetUpdatePlaceholders returns a slice of placeholders for UPDATE query
+// GetUpdatePlaceholders returns a slice of placeholders for UPDATE query, with
+// raw SQL expressions inlined
 func (row *Row) GetUpdatePlaceholders(driver string) []string {
 	placeholders := make([]string, row.GetUpdateColumnsLength())
-	for i, c := range row.GetUpdateColumns() {
-		if driver == postgresDriver {
-			placeholders[i] = fmt.Sprintf("%s = $%d", c, i+1)
-		} else {
-			placeholders[i] = fmt.Sprintf("%s = ?", c)
-		}
+	n := 0
+	for i, c := range row.quotedUpdateColumns(driver) {
+		placeholders[i] = fmt.Sprintf("%s = %s", c, placeholder(driver, row.updateValues[i], &n))
 	}
 	return placeholders
 }
 
-// GetWhere returns a where condition based on primary key with placeholders
+// GetWhere returns a where condition based on primary key, or the match
+// columns, with placeholders numbered after the first i bind values and raw
+// SQL expressions inlined
 func (row *Row) GetWhere(driver string, i int) string {
-	wheres := make([]string, len(row.PK))
-	j := i
-	for _, c := range row.pkColumns {
-		if driver == postgresDriver {
-			wheres[i-j] = fmt.Sprintf("%s = $%d", c, i+1)
-		} else {
-			wheres[i-j] = fmt.Sprintf("%s = ?", c)
-		}
-		i++
+	wheres := make([]string, len(row.pkColumns))
+	for j, c := range row.pkColumns {
+		wheres[j] = fmt.Sprintf("%s = %s", quoteIdentifier(driver, c), placeholder(driver, row.pkValues[j], &i))
 	}
 	return strings.Join(wheres, " AND ")
 }
 
-// GetPKValues returns a slice of primary key values
+// GetPKValues returns a slice of primary key values, or match values, without
+// the raw SQL expressions inlined by GetWhere
 func (row *Row) GetPKValues() []interface{} {
-	return row.pkValues
+	return bindValues(row.pkValues)
+}
+
+// placeholder returns the bind placeholder for a value, counting bind values
+// in n, or the value itself for raw SQL expressions
+func placeholder(driver string, value interface{}, n *int) string {
+	if expression, ok := value.(SQL); ok {
+		return string(expression)
+	}
+	*n++
+	if driver == postgresDriver {
+		return fmt.Sprintf("$%d", *n)
+	}
+	return "?"
+}
+
+// bindValues returns the values sent as bind parameters, leaving out raw SQL
+// expressions
+func bindValues(values []interface{}) []interface{} {
+	bind := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		if _, ok := value.(SQL); !ok {
+			bind = append(bind, value)
+		}
+	}
+	return bind
+}
+
+// addInsertValue adds a column to the INSERT query only
+func (row *Row) addInsertValue(column string, value interface{}) {
+	row.insertColumns = append(row.insertColumns, column)
+	row.insertValues = append(row.insertValues, value)
+	row.insertColumnLength++
+}
+
+// computedValue returns the value Init computed for a column, on insert or
+// else on update
+func (row *Row) computedValue(column string) (interface{}, bool) {
+	for i, c := range row.insertColumns {
+		if c == column {
+			return row.insertValues[i], true
+		}
+	}
+	for i, c := range row.updateColumns {
+		if c == column {
+			return row.updateValues[i], true
+		}
+	}
+	return nil, false
+}
+
+// key returns the columns identifying the row, its primary key or else its
+// match columns
+func (row *Row) key() map[string]interface{} {
+	if len(row.PK) == 0 {
+		return row.Match
+	}
+	return row.PK
+}
+
+// tableName returns the schema-qualified table name without quotes
+func (row *Row) tableName() string {
+	if row.schema == "" {
+		return row.table
+	}
+	return row.schema + "." + row.table
+}
+
+// splitTable splits a dotted table name into schema and table
+func splitTable(name string) (string, string) {
+	if i := strings.Index(name, "."); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+// quoteTable quotes a table name which may be schema-qualified with dotted
+// syntax
+func quoteTable(driver string, name string) string {
+	row := Row{Table: name}
+	row.schema, row.table = splitTable(name)
+	return row.GetTable(driver)
+}
+
+// quoteIdentifier quotes a single identifier for the driver, MySQL uses
+// backticks and every other database double quotes
+func quoteIdentifier(driver string, name string) string {
+	if driver == mysqlDriver {
+		return "`" + strings.Replace(name, "`", "``", -1) + "`"
+	}
+	return "\"" + strings.Replace(name, "\"", "\"\"", -1) + "\""
 }
@@ -58,10 +58,90 @@ func TestRow(t *testing.T) {
 	assert.Equal(t, expectedStrings, row.GetUpdatePlaceholders("sqlite"))
 
 	// Test where clause
-	expectedString = "other_id = $3 AND some_id = $4"
+	expectedString = "\"other_id\" = $3 AND \"some_id\" = $4"
 	assert.Equal(t, expectedString, row.GetWhere("postgres", 2))
 
+	// Test MySQL identifiers
+	expectedStrings = []string{"`other_id`", "`some_id`",
+		"`boolean_field`", "`created_at`", "`string_field`"}
+	assert.Equal(t, expectedStrings, row.quotedInsertColumns("mysql"))
+	expectedStrings = []string{"`other_id` = ?", "`some_id` = ?",
+		"`boolean_field` = ?", "`string_field` = ?", "`updated_at` = ?"}
+	assert.Equal(t, expectedStrings, row.GetUpdatePlaceholders("mysql"))
+	assert.Equal(t, "`other_id` = ? AND `some_id` = ?", row.GetWhere("mysql", 0))
+
 	// Test primary key values
 	expectedInterfaces = []interface{}{interface{}(2), interface{}(1)}
 	assert.Equal(t, expectedInterfaces, row.GetPKValues())
 }
+
+func TestRowGetTable(t *testing.T) {
+	var row *Row
+
+	// Plain table names
+	row = &Row{Table: "some_table"}
+	row.Init()
+	assert.Equal(t, "\"some_table\"", row.GetTable("postgres"))
+	assert.Equal(t, "`some_table`", row.GetTable("mysql"))
+	assert.Equal(t, "some_table", row.tableName())
+
+	// Dotted schema-qualified table names
+	row = &Row{Table: "billing.invoices"}
+	row.Init()
+	assert.Equal(t, "\"billing\".\"invoices\"", row.GetTable("postgres"))
+	assert.Equal(t, "\"billing\".\"invoices\"", row.GetTable("sqlite"))
+	assert.Equal(t, "`billing`.`invoices`", row.GetTable("mysql"))
+	assert.Equal(t, "billing.invoices", row.tableName())
+
+	// Separate schema key
+	row = &Row{Table: "invoices", Schema: "billing"}
+	row.Init()
+	assert.Equal(t, "\"billing\".\"invoices\"", row.GetTable("postgres"))
+	assert.Equal(t, "billing.invoices", row.tableName())
+
+	// Quotes inside identifiers are escaped
+	row = &Row{Table: "weird\"table"}
+	row.Init()
+	assert.Equal(t, "\"weird\"\"table\"", row.GetTable("postgres"))
+	assert.Equal(t, "`a``b`", quoteTable("mysql", "a`b"))
+}
+
+func TestRowInlinesSQLExpressions(t *testing.T) {
+	// Create a test Row instance with raw SQL expressions between bind values
+	row := &Row{
+		Table: "some_table",
+		PK: map[string]interface{}{
+			"a_id": 1,
+			"b_id": SQL("lower('B')"),
+			"c_id": 3,
+		},
+		Fields: map[string]interface{}{
+			"a_field":    "foo",
+			"b_location": SQL("ST_MakePoint(1, 2)"),
+			"c_field":    "bar",
+			"d_deleted":  SQL("now() - interval '3 days'"),
+		},
+	}
+	assert.Nil(t, row.Init())
+
+	// Placeholders skip the expressions, numbering stays continuous
+	assert.Equal(t, []string{
+		"$1", "lower('B')", "$2", "$3", "ST_MakePoint(1, 2)", "$4", "now() - interval '3 days'",
+	}, row.GetInsertPlaceholders("postgres"))
+	assert.Equal(t, []string{
+		"\"a_id\" = $1", "\"b_id\" = lower('B')", "\"c_id\" = $2", "\"a_field\" = $3",
+		"\"b_location\" = ST_MakePoint(1, 2)", "\"c_field\" = $4", "\"d_deleted\" = now() - interval '3 days'",
+	}, row.GetUpdatePlaceholders("postgres"))
+	assert.Equal(t, []string{
+		"?", "lower('B')", "?", "?", "ST_MakePoint(1, 2)", "?", "now() - interval '3 days'",
+	}, row.GetInsertPlaceholders("sqlite"))
+
+	// Only bind values are returned
+	assert.Equal(t, []interface{}{1, 3, "foo", "bar"}, row.GetInsertValues())
+	assert.Equal(t, []interface{}{1, 3, "foo", "bar"}, row.GetUpdateValues())
+
+	// The where clause continues after the update values
+	assert.Equal(t, "\"a_id\" = $5 AND \"b_id\" = lower('B') AND \"c_id\" = $6",
+		row.GetWhere("postgres", len(row.GetUpdateValues())))
+	assert.Equal(t, []interface{}{1, 3}, row.GetPKValues())
+}
@@ -0,0 +1,125 @@
+package fixtures
+
+import (
+	"fmt"
+)
+
+// resetSequences sets the sequences of serial, identity and auto increment
+// columns of every table to the largest value in use, so rows inserted after
+// loading a fixture with explicit keys do not collide with it
+func resetSequences(q queryer, driver string, tables []Row) error {
+	for i := range tables {
+		var err error
+		switch driver {
+		case postgresDriver:
+			err = resetPostgresSequences(q, &tables[i])
+		case sqliteDriver, sqlite3Driver:
+			err = resetSQLiteSequence(q, &tables[i])
+		case mysqlDriver:
+			err = resetMySQLAutoIncrement(q, &tables[i])
+		}
+		if err != nil {
+			return fmt.Errorf("Error resetting sequences of %s: %s", tables[i].tableName(), err)
+		}
+	}
+	return nil
+}
+
+// resetPostgresSequences resets the sequence of every serial and identity
+// column of a table
+func resetPostgresSequences(q queryer, table *Row) error {
+	// Find every column owning a sequence
+	rows, err := q.Query(`
+		SELECT a.attname, pg_get_serial_sequence($1::text, a.attname)
+		FROM pg_attribute a
+		WHERE a.attrelid = $1::text::regclass
+			AND a.attnum > 0
+			AND NOT a.attisdropped
+			AND pg_get_serial_sequence($1::text, a.attname) IS NOT NULL
+		ORDER BY a.attnum
+	`, table.GetTable(postgresDriver))
+	if err != nil {
+		return err
+	}
+	var columns, sequences []string
+	for rows.Next() {
+		var column, sequence string
+		if err := rows.Scan(&column, &sequence); err != nil {
+			rows.Close()
+			return err
+		}
+		columns = append(columns, column)
+		sequences = append(sequences, sequence)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	// Set each sequence to the largest value, or restart it on empty tables
+	for i, column := range columns {
+		column = quoteIdentifier(postgresDriver, column)
+		_, err := q.Exec(fmt.Sprintf(`
+			SELECT pg_catalog.setval($1, COALESCE(MAX(%s), 1), MAX(%s) IS NOT NULL)
+			FROM %s
+		`, column, column, table.GetTable(postgresDriver)), sequences[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resetSQLiteSequence resets the sqlite_sequence entry of an AUTOINCREMENT
+// table, other rowid tables always continue from their largest rowid
+func resetSQLiteSequence(q queryer, table *Row) error {
+	prefix := ""
+	if table.schema != "" {
+		prefix = quoteIdentifier(sqliteDriver, table.schema) + "."
+	}
+
+	// sqlite_sequence only exists once an AUTOINCREMENT table has been created
+	var count int
+	err := q.QueryRow(fmt.Sprintf(
+		`SELECT COUNT(*) FROM %ssqlite_master WHERE type = 'table' AND name = 'sqlite_sequence'`,
+		prefix,
+	)).Scan(&count)
+	if err != nil || count == 0 {
+		return err
+	}
+	err = q.QueryRow(fmt.Sprintf(
+		`SELECT COUNT(*) FROM %ssqlite_sequence WHERE name = ?`,
+		prefix,
+	), table.table).Scan(&count)
+	if err != nil || count == 0 {
+		return err
+	}
+
+	_, err = q.Exec(fmt.Sprintf(
+		`UPDATE %ssqlite_sequence SET seq = (SELECT COALESCE(MAX(rowid), 0) FROM %s) WHERE name = ?`,
+		prefix,
+		table.GetTable(sqliteDriver),
+	), table.table)
+	return err
+}
+
+// resetMySQLAutoIncrement resets the AUTO_INCREMENT counter of a table, which
+// MySQL raises to one above the largest value in use
+func resetMySQLAutoIncrement(q queryer, table *Row) error {
+	var count int
+	err := q.QueryRow(`
+		SELECT COUNT(*)
+		FROM INFORMATION_SCHEMA.COLUMNS
+		WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE())
+			AND TABLE_NAME = ?
+			AND EXTRA LIKE '%auto_increment%'
+	`, table.schema, table.table).Scan(&count)
+	if err != nil || count == 0 {
+		return err
+	}
+
+	_, err = q.Exec(fmt.Sprintf(`ALTER TABLE %s AUTO_INCREMENT = 1`, table.GetTable(mysqlDriver)))
+	return err
+}
@@ -0,0 +1,209 @@
+// Package sqlitetest provides fast, isolated SQLite databases for tests.
+//
+// A Snapshot copies a SQLite database with its schema and fixtures into
+// memory using the online backup API. Restoring it takes milliseconds,
+// instead of re-running the schema and fixtures.Load for every test:
+//
+//	var snapshot *sqlitetest.Snapshot
+//
+//	func TestMain(m *testing.M) {
+//		db, _ := sql.Open("sqlite3", ":memory:")
+//		db.Exec(schema)
+//		fixtures.LoadFiles(files, db, "sqlite")
+//		snapshot, _ = sqlitetest.NewSnapshot(db)
+//		db.Close()
+//		os.Exit(m.Run())
+//	}
+//
+//	func TestSomething(t *testing.T) {
+//		t.Parallel()
+//		db := snapshot.NewDB(t)
+//		// ...
+//	}
+package sqlitetest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// memoryDBCount makes the names of shared in-memory databases unique
+var memoryDBCount int64
+
+var (
+	// backupRetries is how many times a backup retries while the source
+	// database is busy or locked, waiting backupRetryDelay at first and
+	// doubling the delay up to backupMaxRetryDelay
+	backupRetries       = 50
+	backupRetryDelay    = time.Millisecond
+	backupMaxRetryDelay = 100 * time.Millisecond
+)
+
+// Snapshot is an in-memory copy of a SQLite database
+type Snapshot struct {
+	db *sql.DB
+}
+
+// NewSnapshot copies the main database of db into memory
+func NewSnapshot(db *sql.DB) (*Snapshot, error) {
+	snapshot, err := newSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := backup(snapshot.db, db); err != nil {
+		snapshot.Close()
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// ReadSnapshot reads a snapshot saved to a file into memory
+func ReadSnapshot(filename string) (*Snapshot, error) {
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return NewSnapshot(db)
+}
+
+// Save writes the snapshot to a SQLite database file
+func (s *Snapshot) Save(filename string) error {
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return backup(db, s.db)
+}
+
+// Restore replaces the main database of db with the snapshot. A private
+// :memory: database exists once per connection, so it must be limited to a
+// single connection with db.SetMaxOpenConns(1).
+func (s *Snapshot) Restore(db *sql.DB) error {
+	return backup(db, s.db)
+}
+
+// NewDB restores the snapshot into a new shared in-memory database, which is
+// closed when the test finishes. Every call returns a separate database, so
+// parallel tests do not see each other's changes.
+func (s *Snapshot) NewDB(t testing.TB) *sql.DB {
+	t.Helper()
+
+	db, err := openMemoryDB()
+	if err != nil {
+		t.Fatalf("Error opening in-memory database: %s", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	if err := s.Restore(db); err != nil {
+		t.Fatalf("Error restoring snapshot: %s", err)
+	}
+
+	return db
+}
+
+// Close frees the memory held by the snapshot
+func (s *Snapshot) Close() error {
+	return s.db.Close()
+}
+
+// newSnapshot returns an empty snapshot
+func newSnapshot() (*Snapshot, error) {
+	db, err := openMemoryDB()
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{db: db}, nil
+}
+
+// openMemoryDB opens a new, uniquely named in-memory database. It uses a
+// shared cache, so all connections of the pool see the same data, and is
+// freed once the last connection is closed.
+func openMemoryDB() (*sql.DB, error) {
+	n := atomic.AddInt64(&memoryDBCount, 1)
+	db, err := sql.Open(
+		"sqlite3",
+		fmt.Sprintf("file:fixtures_sqlitetest_%d?mode=memory&cache=shared", n),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep a connection open, or the database is freed when the pool is idle
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// backup copies the main database of src over the main database of dst
+func backup(dst, src *sql.DB) error {
+	ctx := context.Background()
+
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	return dstConn.Raw(func(dstDriverConn interface{}) error {
+		return srcConn.Raw(func(srcDriverConn interface{}) error {
+			dstSQLiteConn, ok := dstDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("Destination is not a SQLite database")
+			}
+			srcSQLiteConn, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("Source is not a SQLite database")
+			}
+
+			b, err := dstSQLiteConn.Backup("main", srcSQLiteConn, "main")
+			if err != nil {
+				return err
+			}
+
+			// Copy all pages in one step, retrying with a growing delay while
+			// the source is busy or locked
+			delay := backupRetryDelay
+			for retries := 0; ; retries++ {
+				done, err := b.Step(-1)
+				if err != nil {
+					b.Close()
+					return err
+				}
+				if done {
+					break
+				}
+				if retries == backupRetries {
+					b.Close()
+					return fmt.Errorf("Source database is locked, backup gave up after %d retries", backupRetries)
+				}
+				time.Sleep(delay)
+				if delay < backupMaxRetryDelay {
+					delay *= 2
+				}
+			}
+			return b.Close()
+		})
+	})
+}
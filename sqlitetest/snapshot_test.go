@@ -0,0 +1,147 @@
+package sqlitetest
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"testing"
+
+	fixtures "github.com/AreaHQ/go-fixtures"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testSQLiteDb     = "/tmp/fixtures_sqlitetest_testdb.sqlite"
+	testSnapshotFile = "/tmp/fixtures_sqlitetest_snapshot.sqlite"
+)
+
+var testSchema = `
+CREATE TABLE some_table(
+  id INT PRIMARY KEY NOT NULL,
+  string_field VARCHAR(50) NOT NULL
+);
+`
+
+var testData = `
+---
+- table: 'some_table'
+  pk:
+    id: 1
+  fields:
+    string_field: 'foobar'
+- table: 'some_table'
+  pk:
+    id: 2
+  fields:
+    string_field: 'bazqux'
+`
+
+func TestSnapshotRestoresDatabaseSQLite(t *testing.T) {
+	// Delete the test database and snapshot file
+	os.Remove(testSQLiteDb)
+	os.Remove(testSnapshotFile)
+	defer os.Remove(testSnapshotFile)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to a SQLite database and load the fixture once
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(testSchema)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = fixtures.Load([]byte(testData), db, "sqlite")
+	assert.Nil(t, err)
+
+	// Take a snapshot
+	snapshot, err := NewSnapshot(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer snapshot.Close()
+
+	var count int
+
+	// Changes to the database are undone by restoring the snapshot
+	_, err = db.Exec("DELETE FROM some_table")
+	assert.Nil(t, err)
+	err = snapshot.Restore(db)
+	assert.Nil(t, err)
+	db.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+	assert.Equal(t, 2, count)
+
+	// Parallel tests get their own in-memory copy
+	t.Run("Group", func(t *testing.T) {
+		for _, id := range []int{1, 2} {
+			id := id
+			t.Run("Delete", func(t *testing.T) {
+				t.Parallel()
+				db := snapshot.NewDB(t)
+				_, err := db.Exec("DELETE FROM some_table WHERE id = ?", id)
+				assert.Nil(t, err)
+				var count int
+				db.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+				assert.Equal(t, 1, count)
+				err = fixtures.Verify([]byte(testData), db, "sqlite")
+				assert.NotNil(t, err)
+			})
+		}
+	})
+
+	// Snapshots can be saved to and read from files
+	err = snapshot.Save(testSnapshotFile)
+	assert.Nil(t, err)
+	fileSnapshot, err := ReadSnapshot(testSnapshotFile)
+	assert.Nil(t, err)
+	defer fileSnapshot.Close()
+	db = fileSnapshot.NewDB(t)
+	fixtures.Assert(t, []byte(testData), db, "sqlite", fixtures.Strict())
+}
+
+func TestSnapshotGivesUpOnALockedDatabaseSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	// Retry quickly
+	defer func(retries int) { backupRetries = retries }(backupRetries)
+	backupRetries = 3
+
+	// Don't wait for locks in SQLite either
+	db, err := sql.Open("sqlite3", testSQLiteDb+"?_busy_timeout=0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(testSchema)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Lock the database from another connection
+	locker, err := sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer locker.Close()
+	conn, err := locker.Conn(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	_, err = conn.ExecContext(context.Background(), `BEGIN EXCLUSIVE`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.ExecContext(context.Background(), `ROLLBACK`)
+
+	_, err = NewSnapshot(db)
+	assert.EqualError(t, err, "Source database is locked, backup gave up after 3 retries")
+}
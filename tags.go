@@ -0,0 +1,337 @@
+package fixtures
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// yamlTags are the custom tags understood in fixtures
+var yamlTags = map[string]bool{
+	"now":     true,
+	"sql":     true,
+	"ref":     true,
+	"file":    true,
+	"env":     true,
+	"json":    true,
+	"literal": true,
+}
+
+// tagHandlers convert the values of custom tags when a fixture is decoded,
+// with paths relative to the fixture's directory. Text read from outside the
+// fixture is a literal, so it is never parsed as fixture syntax. References
+// are resolved later, when the rows they point at have been loaded.
+var tagHandlers = map[string]func(value, dir string) (interface{}, error){
+	"now": func(value, dir string) (interface{}, error) {
+		return "NOW()", nil
+	},
+	"sql": func(value, dir string) (interface{}, error) {
+		if value == "" {
+			return nil, fmt.Errorf("!sql needs an expression")
+		}
+		return SQL(value), nil
+	},
+	"file": func(value, dir string) (interface{}, error) {
+		if !filepath.IsAbs(value) {
+			value = filepath.Join(dir, value)
+		}
+		return ioutil.ReadFile(value)
+	},
+	"env": func(value, dir string) (interface{}, error) {
+		return Literal(os.Getenv(value)), nil
+	},
+	"json": func(value, dir string) (interface{}, error) {
+		var v interface{}
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return nil, fmt.Errorf("Invalid !json value: %s", err)
+		}
+		return Literal(value), nil
+	},
+	"literal": func(value, dir string) (interface{}, error) {
+		return Literal(value), nil
+	},
+}
+
+// decodeTags converts the tagged values of a fixture with their handlers
+func decodeTags(document *fixtureDocument, dir string) error {
+	for table, values := range document.Defaults {
+		if err := decodeValues(values, dir); err != nil {
+			return fmt.Errorf("Error loading defaults of %s: %s", table, err)
+		}
+	}
+	for i, row := range document.Rows {
+		for _, values := range []map[string]interface{}{row.PK, row.Match, row.Fields} {
+			if err := decodeValues(values, dir); err != nil {
+				return NewProcessingError(i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// decodeValues converts tagged values with their handlers
+func decodeValues(values map[string]interface{}, dir string) error {
+	for column, value := range values {
+		tag, s, ok := tagValue(value)
+		if !ok || tagHandlers[tag] == nil {
+			continue
+		}
+		decoded, err := tagHandlers[tag](s, dir)
+		if err != nil {
+			return err
+		}
+		values[column] = decoded
+	}
+	return nil
+}
+
+// rewriteTags rewrites custom tags, which the YAML decoder drops, into
+// single key mappings before unmarshaling, so `!ref users.alice.id` decodes
+// as {"!ref": "users.alice.id"}
+func rewriteTags(data []byte) []byte {
+	var buf bytes.Buffer
+	flow := 0   // depth of flow collections
+	plain := -1 // indentation of the line a multi-line plain scalar starts on
+	for i := 0; i < len(data); {
+		c := data[i]
+		tokenStart := i == 0 || bytes.IndexByte([]byte(" \t\r\n[{,"), data[i-1]) >= 0
+
+		// Plain scalars continue on more indented lines, which are text
+		if flow == 0 && (i == 0 || data[i-1] == '\n') {
+			end := lineEnd(data, i)
+			line := data[i:end]
+			content := bytes.TrimSpace(line)
+			indent := len(line) - len(bytes.TrimLeft(line, " "))
+			if len(content) > 0 && plain >= 0 && indent > plain && content[0] != '#' {
+				buf.Write(line)
+				i = end
+				continue
+			}
+			if len(content) > 0 {
+				plain = -1
+				if endsInPlainScalar(content) {
+					plain = indent
+				}
+			}
+		}
+
+		switch {
+		case valueStart(data, i) && (c == '\'' || c == '"'):
+			end := quotedEnd(data, i)
+			buf.Write(data[i:end])
+			i = end
+		case tokenStart && c == '#':
+			end := lineEnd(data, i)
+			buf.Write(data[i:end])
+			i = end
+		case flow == 0 && valueStart(data, i) && (c == '|' || c == '>'):
+			// Block scalars are text, tags in them are not rewritten
+			end, ok := blockScalarEnd(data, i)
+			if !ok {
+				buf.WriteByte(c)
+				i++
+				continue
+			}
+			buf.Write(data[i:end])
+			i = end
+		case c == '[' || c == '{':
+			if tokenStart || flow > 0 {
+				flow++
+			}
+			buf.WriteByte(c)
+			i++
+		case (c == ']' || c == '}') && flow > 0:
+			flow--
+			buf.WriteByte(c)
+			i++
+		case valueStart(data, i) && c == '!':
+			tag, value, end, ok := readTag(data, i, flow > 0)
+			if !ok {
+				buf.WriteByte(c)
+				i++
+				continue
+			}
+			buf.WriteString(`{"!` + tag + `": ` + value + `}`)
+			i = end
+		default:
+			buf.WriteByte(c)
+			i++
+		}
+	}
+	return buf.Bytes()
+}
+
+// endsInPlainScalar returns true if a line of a block collection ends in a
+// plain scalar value, such as "name: Alice" or "- Alice"
+func endsInPlainScalar(line []byte) bool {
+	if len(line) > 0 && line[0] == '#' {
+		return false
+	}
+	value := false
+	for len(line) > 0 && line[0] == '-' && (len(line) == 1 || line[1] == ' ' || line[1] == '\t') {
+		line = bytes.TrimLeft(line[1:], " \t")
+		value = true
+	}
+	if len(line) > 0 && (line[0] == '\'' || line[0] == '"') {
+		end := quotedEnd(line, 0)
+		if end < len(line) && line[end] == ':' {
+			line = bytes.TrimLeft(line[end+1:], " \t")
+			value = true
+		}
+	} else if j := bytes.Index(line, []byte(": ")); j >= 0 {
+		line = bytes.TrimLeft(line[j+1:], " \t")
+		value = true
+	} else if bytes.HasSuffix(line, []byte(":")) {
+		return false
+	}
+
+	// Quoted, flow, block, tagged, anchored and aliased values are not plain
+	return value && len(line) > 0 && bytes.IndexByte([]byte("'\"[{|>!&*#"), line[0]) < 0
+}
+
+// valueStart returns true if a scalar can start at i, rather than i being in
+// the middle of a plain scalar such as "don't !ref me"
+func valueStart(data []byte, i int) bool {
+	for j := i - 1; j >= 0; j-- {
+		switch data[j] {
+		case ' ', '\t':
+			continue
+		case '\n', '\r', ':', '-', '?', '[', '{', ',':
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// readTag reads a custom tag and its scalar value starting at i, returning
+// the value as a quoted YAML string and the position after it
+func readTag(data []byte, i int, inFlow bool) (string, string, int, bool) {
+	start := i + 1
+	end := start
+	for end < len(data) && isTagChar(data[end]) {
+		end++
+	}
+	tag := string(data[start:end])
+	if !yamlTags[tag] || (end < len(data) && bytes.IndexByte([]byte(" \t\r\n,]}"), data[end]) < 0) {
+		return "", "", 0, false
+	}
+
+	// Skip spaces between the tag and its value
+	for end < len(data) && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	if end < len(data) && (data[end] == '\'' || data[end] == '"') {
+		valueEnd := quotedEnd(data, end)
+		return tag, string(data[end:valueEnd]), valueEnd, true
+	}
+
+	// Plain scalars run to the end of the line, a comment or, in flow
+	// collections, the next separator
+	valueEnd := end
+	for valueEnd < len(data) && data[valueEnd] != '\n' && data[valueEnd] != '\r' {
+		if inFlow && bytes.IndexByte([]byte(",]}"), data[valueEnd]) >= 0 {
+			break
+		}
+		if data[valueEnd] == '#' && (data[valueEnd-1] == ' ' || data[valueEnd-1] == '\t') {
+			break
+		}
+		valueEnd++
+	}
+	value := bytes.TrimRight(data[end:valueEnd], " \t")
+	return tag, strconv.Quote(string(value)), end + len(value), true
+}
+
+// isTagChar returns true for characters allowed in custom tag names
+func isTagChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// quotedEnd returns the position after the quoted scalar starting at i
+func quotedEnd(data []byte, i int) int {
+	quote := data[i]
+	for j := i + 1; j < len(data); j++ {
+		switch {
+		case quote == '"' && data[j] == '\\':
+			j++
+		case data[j] == quote && quote == '\'' && j+1 < len(data) && data[j+1] == '\'':
+			j++
+		case data[j] == quote:
+			return j + 1
+		}
+	}
+	return len(data)
+}
+
+// blockScalarEnd returns the position after the literal or folded block
+// scalar whose header starts at i. Its content is every following line that
+// is empty or indented at least as much as the first line that is not.
+func blockScalarEnd(data []byte, i int) (int, bool) {
+	// The header is the indicator, optional chomping and indentation
+	// indicators, and an optional comment
+	j := i + 1
+	for j < len(data) && bytes.IndexByte([]byte("+-0123456789"), data[j]) >= 0 {
+		j++
+	}
+	for j < len(data) && (data[j] == ' ' || data[j] == '\t') {
+		j++
+	}
+	if j < len(data) && data[j] == '#' && (data[j-1] == ' ' || data[j-1] == '\t') {
+		j = lineEnd(data, j)
+	}
+	if j < len(data) && data[j] != '\n' && data[j] != '\r' {
+		return 0, false
+	}
+
+	end := j
+	indent := -1
+	for end < len(data) {
+		lineStart := end + 1
+		next := lineEnd(data, lineStart)
+		if lineStart > len(data) {
+			break
+		}
+		line := data[lineStart:next]
+		spaces := len(line) - len(bytes.TrimLeft(line, " "))
+		if len(bytes.TrimSpace(line)) > 0 {
+			if indent < 0 {
+				indent = spaces
+			}
+			if spaces < indent || indent == 0 {
+				break
+			}
+		}
+		end = next
+	}
+	return end, true
+}
+
+// lineEnd returns the position of the end of the line containing i
+func lineEnd(data []byte, i int) int {
+	if j := bytes.IndexByte(data[i:], '\n'); j >= 0 {
+		return i + j
+	}
+	return len(data)
+}
+
+// tagValue returns the tag and value of a custom tag decoded by rewriteTags
+func tagValue(v interface{}) (string, string, bool) {
+	m, ok := v.(map[interface{}]interface{})
+	if !ok || len(m) != 1 {
+		return "", "", false
+	}
+	for k, value := range m {
+		key, ok := k.(string)
+		if !ok || len(key) < 2 || key[0] != '!' || !yamlTags[key[1:]] {
+			return "", "", false
+		}
+		s, _ := value.(string)
+		return key[1:], s, true
+	}
+	return "", "", false
+}
@@ -0,0 +1,89 @@
+package fixtures
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteTags(t *testing.T) {
+	testCases := []struct {
+		yaml     string
+		expected string
+	}{
+		{
+			"author_id: !ref users.alice.id\n",
+			"author_id: {\"!ref\": \"users.alice.id\"}\n",
+		},
+		{
+			"author_id: !ref users.alice.id # comment\n",
+			"author_id: {\"!ref\": \"users.alice.id\"} # comment\n",
+		},
+		{
+			"author_id: !ref 'users.alice.id'",
+			"author_id: {\"!ref\": 'users.alice.id'}",
+		},
+		{
+			"ids: [!ref users.alice.id, !ref users.bob.id]",
+			"ids: [{\"!ref\": \"users.alice.id\"}, {\"!ref\": \"users.bob.id\"}]",
+		},
+		{
+			"name: 'not !ref a tag'\nother: don't !ref me\n",
+			"name: 'not !ref a tag'\nother: don't !ref me\n",
+		},
+		{
+			"created_at: !now\nid: !sql \"gen_random_uuid()\"\n",
+			"created_at: {\"!now\": \"\"}\nid: {\"!sql\": \"gen_random_uuid()\"}\n",
+		},
+		{
+			"name: !unknown foo\n",
+			"name: !unknown foo\n",
+		},
+		{
+			"note: |\n  see: !ref users.alice.id\n\n  also !env HOME\nauthor_id: !ref users.alice.id\n",
+			"note: |\n  see: !ref users.alice.id\n\n  also !env HOME\nauthor_id: {\"!ref\": \"users.alice.id\"}\n",
+		},
+		{
+			"- note: >-\n    !sql now()\n  id: !sql now()\n",
+			"- note: >-\n    !sql now()\n  id: {\"!sql\": \"now()\"}\n",
+		},
+		{
+			"a: b | !ref users.alice.id\n",
+			"a: b | !ref users.alice.id\n",
+		},
+		{
+			"note: hello\n      !env HOME\n\n  !env HOME\nid: !env HOME\n",
+			"note: hello\n      !env HOME\n\n  !env HOME\nid: {\"!env\": \"HOME\"}\n",
+		},
+		{
+			"- hello\n  !ref users.alice.id\n- !ref users.alice.id\n",
+			"- hello\n  !ref users.alice.id\n- {\"!ref\": \"users.alice.id\"}\n",
+		},
+		{
+			"# note: hello\n  !env HOME\n",
+			"# note: hello\n  {\"!env\": \"HOME\"}\n",
+		},
+		{
+			"fields:\n  home:\n    !env HOME\n",
+			"fields:\n  home:\n    {\"!env\": \"HOME\"}\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, string(rewriteTags([]byte(testCase.yaml))))
+	}
+}
+
+func TestParseFixtureDecodesTags(t *testing.T) {
+	rows, err := parseFixture([]byte(`
+- table: 'posts'
+  fields:
+    author_id: !ref users.alice.id
+`), ".", newOptions(nil))
+	assert.Nil(t, err)
+
+	tag, value, ok := tagValue(rows[0].Fields["author_id"])
+	assert.True(t, ok)
+	assert.Equal(t, "ref", tag)
+	assert.Equal(t, "users.alice.id", value)
+}
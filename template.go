@@ -0,0 +1,114 @@
+package fixtures
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"text/template"
+	"time"
+)
+
+// templateOptions holds the data and functions fixtures are executed with
+type templateOptions struct {
+	data  interface{}
+	funcs template.FuncMap
+}
+
+// Template runs fixtures through text/template before unmarshaling them, with
+// data as dot and funcs added to, or overriding, the built-in functions:
+//
+//	sequence start end     integers from start to end, to range over
+//	now                    the time the fixture is executed
+//	addDate y m d t        t moved by years, months and days
+//	addDuration "2h" t     t moved by a duration
+//	date "2006-01-02" t    t formatted with a layout
+//	uuid                   a random UUID
+//	env "NAME"             an environment variable
+//
+// For example, 50 users named user_1 to user_50:
+//
+//	{{ range sequence 1 50 }}
+//	- table: 'users'
+//	  pk:
+//	    id: {{ . }}
+//	  fields:
+//	    name: 'user_{{ . }}'
+//	    created_at: '{{ now | addDate 0 0 -3 | date "2006-01-02" }}'
+//	{{ end }}
+//
+// Template errors report the fixture file and line. Rows generated with a
+// count use [[ ]] delimiters for their own templates in such fixtures.
+func Template(data interface{}, funcs template.FuncMap) Option {
+	return func(o *options) {
+		o.template = &templateOptions{data: data, funcs: funcs}
+	}
+}
+
+// expandTemplate executes a fixture read from filename as a template, if the
+// Template option is set, with now as the reference time
+func expandTemplate(filename string, data []byte, now time.Time, o *options) ([]byte, error) {
+	if o.template == nil {
+		return data, nil
+	}
+
+	// Template errors name the file, or just the fixture for data passed
+	// directly
+	name := filename
+	if name == "" {
+		name = "fixture"
+	}
+
+	tmpl, err := template.New(name).
+		Funcs(templateFuncs(now)).
+		Funcs(o.template.funcs).
+		Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, o.template.data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// templateFuncs returns the built-in template functions, with now fixed for
+// the whole fixture
+func templateFuncs(now time.Time) template.FuncMap {
+	return template.FuncMap{
+		"sequence": func(start, end int) []int {
+			var ints []int
+			for i := start; i <= end; i++ {
+				ints = append(ints, i)
+			}
+			return ints
+		},
+		"now": func() time.Time {
+			return now
+		},
+		"addDate": func(years, months, days int, t time.Time) time.Time {
+			return t.AddDate(years, months, days)
+		},
+		"addDuration": func(duration string, t time.Time) (time.Time, error) {
+			d, err := time.ParseDuration(duration)
+			return t.Add(d), err
+		},
+		"date": func(layout string, t time.Time) string {
+			return t.Format(layout)
+		},
+		"uuid": newUUID,
+		"env":  os.Getenv,
+	}
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return formatUUID(b), nil
+}
@@ -0,0 +1,53 @@
+package fixtures
+
+import (
+	"os"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	os.Setenv("FIXTURES_TEST_NAME", "alice")
+	defer os.Unsetenv("FIXTURES_TEST_NAME")
+
+	o := newOptions([]Option{Template(
+		map[string]int{"Count": 2},
+		template.FuncMap{"upper": func(s string) string { return s + "!" }},
+	)})
+	data, err := expandTemplate("users.yml", []byte(
+		`{{ range sequence 1 .Count }}{{ . }},{{ end }}{{ env "FIXTURES_TEST_NAME" | upper }}`,
+	), time.Now(), o)
+	assert.Nil(t, err)
+	assert.Equal(t, "1,2,alice!", string(data))
+
+	// Dates are computed from a single reference time
+	data, err = expandTemplate("users.yml", []byte(
+		`{{ now | addDate 0 0 -3 | date "2006-01-02" }} {{ now | addDuration "-72h" | date "2006-01-02" }}`,
+	), time.Now(), o)
+	assert.Nil(t, err)
+	date := time.Now().AddDate(0, 0, -3).Format("2006-01-02")
+	assert.Equal(t, date+" "+date, string(data))
+
+	data, err = expandTemplate("users.yml", []byte(`{{ uuid }}`), time.Now(), o)
+	assert.Nil(t, err)
+	assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", string(data))
+
+	// Without the option fixtures are left alone
+	data, err = expandTemplate("users.yml", []byte(`{{ uuid }}`), time.Now(), newOptions(nil))
+	assert.Nil(t, err)
+	assert.Equal(t, "{{ uuid }}", string(data))
+}
+
+func TestExpandTemplateReportsFileAndLine(t *testing.T) {
+	o := newOptions([]Option{Template(nil, nil)})
+
+	_, err := expandTemplate("users.yml", []byte("- table: 'users'\n  name: {{ missing }}\n"), time.Now(), o)
+	assert.EqualError(t, err, `template: users.yml:2: function "missing" not defined`)
+
+	_, err = expandTemplate("users.yml", []byte("\n\n{{ addDuration \"soon\" now }}"), time.Now(), o)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "template: users.yml:3:")
+}
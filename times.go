@@ -0,0 +1,72 @@
+package fixtures
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	// relativeTimeSyntax matches relative time values such as NOW-3d, NOW+2h
+	// or TODAY+1d@09:00, and also the bare words NOW and TODAY, which are not
+	// relative times
+	relativeTimeSyntax = regexp.MustCompile(`^(NOW|TODAY)((?:[+-]\d+[smhdw])*)(?:@(\d{2}):(\d{2})(?::(\d{2}))?)?$`)
+
+	// relativeTimeOffset matches a single offset of a relative time value
+	relativeTimeOffset = regexp.MustCompile(`([+-]\d+)([smhdw])`)
+)
+
+// parseRelativeTime returns the time of a relative time value, computed from
+// the reference time of the load. NOW is the reference time and TODAY its
+// midnight in UTC, moved by offsets in seconds (s), minutes (m), hours (h),
+// days (d) or weeks (w), and optionally set to a UTC time of day with @.
+// Values need an offset or a time of day, so text such as "NOW" is left as
+// it is, use NOW() or NOW+0s for the reference time and TODAY@00:00 for its
+// midnight.
+func parseRelativeTime(v interface{}, now time.Time) (time.Time, bool, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	match := relativeTimeSyntax.FindStringSubmatch(s)
+	if match == nil || match[2] == "" && match[3] == "" {
+		return time.Time{}, false, nil
+	}
+
+	t := now.UTC()
+	if match[1] == "TODAY" {
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	for _, offset := range relativeTimeOffset.FindAllStringSubmatch(match[2], -1) {
+		n, err := strconv.Atoi(offset[1])
+		if err != nil {
+			return time.Time{}, true, fmt.Errorf("Invalid relative time %s: %s", s, err)
+		}
+		switch offset[2] {
+		case "s":
+			t = t.Add(time.Duration(n) * time.Second)
+		case "m":
+			t = t.Add(time.Duration(n) * time.Minute)
+		case "h":
+			t = t.Add(time.Duration(n) * time.Hour)
+		case "d":
+			t = t.AddDate(0, 0, n)
+		case "w":
+			t = t.AddDate(0, 0, 7*n)
+		}
+	}
+
+	if match[3] != "" {
+		hour, _ := strconv.Atoi(match[3])
+		minute, _ := strconv.Atoi(match[4])
+		second, _ := strconv.Atoi(match[5])
+		if hour > 23 || minute > 59 || second > 59 {
+			return time.Time{}, true, fmt.Errorf("Invalid time of day in relative time %s", s)
+		}
+		t = time.Date(t.Year(), t.Month(), t.Day(), hour, minute, second, 0, time.UTC)
+	}
+
+	return t, true, nil
+}
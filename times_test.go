@@ -0,0 +1,56 @@
+package fixtures
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRelativeTime(t *testing.T) {
+	now := time.Date(2016, 3, 10, 15, 30, 45, 0, time.UTC)
+
+	testCases := []struct {
+		value    string
+		expected time.Time
+	}{
+		{"NOW+0s", now},
+		{"NOW-3d", time.Date(2016, 3, 7, 15, 30, 45, 0, time.UTC)},
+		{"NOW+2h", time.Date(2016, 3, 10, 17, 30, 45, 0, time.UTC)},
+		{"NOW+1w-30m+15s", time.Date(2016, 3, 17, 15, 1, 0, 0, time.UTC)},
+		{"TODAY@00:00", time.Date(2016, 3, 10, 0, 0, 0, 0, time.UTC)},
+		{"TODAY+1d@09:00", time.Date(2016, 3, 11, 9, 0, 0, 0, time.UTC)},
+		{"TODAY-1d@23:59:59", time.Date(2016, 3, 9, 23, 59, 59, 0, time.UTC)},
+	}
+	for _, testCase := range testCases {
+		actual, ok, err := parseRelativeTime(testCase.value, now)
+		assert.Nil(t, err)
+		assert.True(t, ok, testCase.value)
+		assert.Equal(t, testCase.expected, actual, testCase.value)
+	}
+
+	// Other values are not relative times
+	for _, value := range []interface{}{"NOW", "TODAY", "NOW()", "NOWHERE", "TODAY+1y", 3} {
+		_, ok, err := parseRelativeTime(value, now)
+		assert.Nil(t, err)
+		assert.False(t, ok)
+	}
+
+	_, ok, err := parseRelativeTime("TODAY@25:00", now)
+	assert.True(t, ok)
+	assert.EqualError(t, err, "Invalid time of day in relative time TODAY@25:00")
+}
+
+func TestRowInitComputesRelativeTimes(t *testing.T) {
+	row := &Row{
+		Table:  "some_table",
+		PK:     map[string]interface{}{"id": 1},
+		Fields: map[string]interface{}{"expires_at": "NOW+1h"},
+		now:    time.Date(2016, 3, 10, 15, 30, 45, 0, time.UTC),
+	}
+	assert.Nil(t, row.Init())
+
+	expected := time.Date(2016, 3, 10, 16, 30, 45, 0, time.UTC)
+	assert.Equal(t, []interface{}{1, expected}, row.GetInsertValues())
+	assert.Equal(t, []interface{}{1, expected}, row.GetUpdateValues())
+}
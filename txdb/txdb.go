@@ -0,0 +1,339 @@
+// Package txdb provides a database/sql driver that runs every connection
+// inside a single transaction on a real database, which is rolled back when
+// the connection is closed. Load fixtures into the real database once, then
+// open a txdb connection per test: every test sees a clean copy of the
+// fixtures and its changes are thrown away afterwards.
+//
+//	func init() {
+//		txdb.Register("txdb", "postgres", "user=foo dbname=bar sslmode=disable")
+//	}
+//
+//	func TestSomething(t *testing.T) {
+//		db, err := sql.Open("txdb", t.Name())
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		defer db.Close()
+//
+//		// ...
+//	}
+//
+// The DSN passed to sql.Open identifies the transaction: every connection a
+// *sql.DB opens with the same identifier shares it, so use a unique one per
+// test. Transactions started with Begin are emulated with savepoints, so
+// fixtures.Load works inside a txdb connection too. Statements of a shared
+// transaction are serialized; drivers such as lib/pq which can not run a
+// statement while the rows of another one are being read need
+// db.SetMaxOpenConns(1).
+//
+// The transaction is rolled back when the pool of the *sql.DB closes its last
+// connection to it, not when db.Close is called. Keep at least one idle
+// connection and no connection lifetime: with db.SetMaxIdleConns(0),
+// db.SetConnMaxLifetime or db.SetConnMaxIdleTime, the pool closes idle
+// connections between queries and changes are lost before the next one.
+package txdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Register registers a database/sql driver called name which wraps the real
+// database identified by driver and dsn. Like sql.Register, it panics if it
+// is called twice with the same name.
+func Register(name, driver, dsn string) {
+	sql.Register(name, &txDriver{
+		driver: driver,
+		dsn:    dsn,
+		conns:  make(map[string]*conn),
+	})
+}
+
+// txDriver opens connections to the real database lazily and keeps one
+// transaction per identifier
+type txDriver struct {
+	driver string
+	dsn    string
+	mu     sync.Mutex
+	db     *sql.DB
+	conns  map[string]*conn
+}
+
+// Open returns the connection for an identifier, starting a new transaction
+// if there is none
+func (d *txDriver) Open(id string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if c, ok := d.conns[id]; ok {
+		c.opened++
+		return c, nil
+	}
+
+	if d.db == nil {
+		db, err := sql.Open(d.driver, d.dsn)
+		if err != nil {
+			return nil, err
+		}
+		d.db = db
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &conn{drv: d, id: id, tx: tx, opened: 1}
+	d.conns[id] = c
+	return c, nil
+}
+
+// conn runs every statement inside the transaction of its identifier
+type conn struct {
+	drv        *txDriver
+	id         string
+	mu         sync.Mutex
+	tx         *sql.Tx
+	opened     int
+	savepoints int
+}
+
+// Close rolls the transaction back once every connection sharing it is
+// closed, and closes the real database once no transaction is left
+func (c *conn) Close() error {
+	c.drv.mu.Lock()
+	defer c.drv.mu.Unlock()
+
+	c.opened--
+	if c.opened > 0 {
+		return nil
+	}
+
+	delete(c.drv.conns, c.id)
+	c.mu.Lock()
+	err := c.tx.Rollback()
+	c.mu.Unlock()
+
+	if len(c.drv.conns) == 0 {
+		if closeErr := c.drv.db.Close(); err == nil {
+			err = closeErr
+		}
+		c.drv.db = nil
+	}
+	return err
+}
+
+// Begin starts a nested transaction
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx starts a nested transaction using a savepoint
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.savepoints++
+	savepoint := fmt.Sprintf("txdb_%d", c.savepoints)
+	if _, err := c.tx.ExecContext(ctx, fmt.Sprintf("SAVEPOINT %s", savepoint)); err != nil {
+		c.savepoints--
+		return nil, err
+	}
+	return &tx{conn: c, savepoint: savepoint}, nil
+}
+
+// Prepare prepares a statement inside the transaction
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext prepares a statement inside the transaction
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, err := c.tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{conn: c, stmt: s}, nil
+}
+
+// ExecContext runs a statement inside the transaction without preparing it,
+// so statements containing several queries work too
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.tx.ExecContext(ctx, query, namedValues(args)...)
+}
+
+// QueryContext runs a query inside the transaction without preparing it
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, err := c.tx.QueryContext(ctx, query, namedValues(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(r)
+}
+
+// tx is a nested transaction backed by a savepoint
+type tx struct {
+	conn      *conn
+	savepoint string
+}
+
+// Commit releases the savepoint, keeping its changes in the transaction
+func (t *tx) Commit() error {
+	t.conn.mu.Lock()
+	defer t.conn.mu.Unlock()
+
+	_, err := t.conn.tx.Exec(fmt.Sprintf("RELEASE SAVEPOINT %s", t.savepoint))
+	return err
+}
+
+// Rollback discards the changes made since the savepoint
+func (t *tx) Rollback() error {
+	t.conn.mu.Lock()
+	defer t.conn.mu.Unlock()
+
+	_, err := t.conn.tx.Exec(fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", t.savepoint))
+	return err
+}
+
+// stmt is a statement prepared inside the transaction
+type stmt struct {
+	conn *conn
+	stmt *sql.Stmt
+}
+
+// Close closes the prepared statement
+func (s *stmt) Close() error {
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+
+	return s.stmt.Close()
+}
+
+// NumInput returns -1, the real driver checks the number of arguments
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+// Exec runs the prepared statement
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+
+	return s.stmt.Exec(values(args)...)
+}
+
+// ExecContext runs the prepared statement
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+
+	return s.stmt.ExecContext(ctx, namedValues(args)...)
+}
+
+// Query runs the prepared query
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+
+	r, err := s.stmt.Query(values(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(r)
+}
+
+// QueryContext runs the prepared query
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+
+	r, err := s.stmt.QueryContext(ctx, namedValues(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(r)
+}
+
+// rows reads query results from the real driver
+type rows struct {
+	rows    *sql.Rows
+	columns []string
+}
+
+// newRows wraps the rows of a query run inside the transaction
+func newRows(r *sql.Rows) (*rows, error) {
+	columns, err := r.Columns()
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return &rows{rows: r, columns: columns}, nil
+}
+
+// Columns returns the column names
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+// Close closes the rows
+func (r *rows) Close() error {
+	return r.rows.Close()
+}
+
+// Next copies the next row into dest
+func (r *rows) Next(dest []driver.Value) error {
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+
+	values := make([]interface{}, len(dest))
+	scanDest := make([]interface{}, len(dest))
+	for i := range values {
+		scanDest[i] = &values[i]
+	}
+	if err := r.rows.Scan(scanDest...); err != nil {
+		return err
+	}
+	for i, value := range values {
+		dest[i] = value
+	}
+	return nil
+}
+
+// values converts driver values to query arguments
+func values(args []driver.Value) []interface{} {
+	result := make([]interface{}, len(args))
+	for i, arg := range args {
+		result[i] = arg
+	}
+	return result
+}
+
+// namedValues converts named driver values to query arguments
+func namedValues(args []driver.NamedValue) []interface{} {
+	result := make([]interface{}, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			result[i] = sql.Named(arg.Name, arg.Value)
+		} else {
+			result[i] = arg.Value
+		}
+	}
+	return result
+}
@@ -0,0 +1,84 @@
+package txdb
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os/exec"
+	"testing"
+
+	fixtures "github.com/AreaHQ/go-fixtures"
+	"github.com/stretchr/testify/assert"
+	// Driver
+	_ "github.com/lib/pq"
+)
+
+var (
+	testPostgresDbUser = "go_fixtures"
+	testPostgresDbName = "go_fixtures_txdb_test"
+	testPostgresDSN    = fmt.Sprintf(
+		"sslmode=disable host=localhost port=5432 user=%s password='' dbname=%s",
+		testPostgresDbUser,
+		testPostgresDbName,
+	)
+)
+
+func TestTxDBRollsBackOnClosePostgres(t *testing.T) {
+	// Rebuild the test database
+	exec.Command("dropdb", "--if-exists", "-U", testPostgresDbUser, testPostgresDbName).Run()
+	out, err := exec.Command("createdb", "-U", testPostgresDbUser, testPostgresDbName).CombinedOutput()
+	if err != nil {
+		log.Fatalf("%s: %s", err, out)
+	}
+
+	// Load the fixture into the real database once
+	db, err := sql.Open("postgres", testPostgresDSN)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(testSchema)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = fixtures.Load([]byte(testData), db, "postgres")
+	assert.Nil(t, err)
+
+	Register("txdb_postgres", "postgres", testPostgresDSN)
+
+	var (
+		count       int
+		stringField string
+	)
+
+	// Change the data inside a txdb connection
+	txDB, err := sql.Open("txdb_postgres", "first")
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = fixtures.Load([]byte(testDataUpdate), txDB, "postgres")
+	assert.Nil(t, err)
+	txDB.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+	assert.Equal(t, 2, count)
+
+	// Failed loads are rolled back to their savepoint only
+	err = fixtures.Load([]byte(`- table: 'missing_table'
+  pk:
+    id: 1
+`), txDB, "postgres")
+	assert.NotNil(t, err)
+	txDB.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+	assert.Equal(t, 2, count)
+	assert.Nil(t, txDB.Close())
+
+	// A new connection sees a clean copy of the fixture
+	txDB, err = sql.Open("txdb_postgres", "second")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer txDB.Close()
+	txDB.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+	assert.Equal(t, 1, count)
+	txDB.QueryRow("SELECT string_field FROM some_table WHERE id = 1").Scan(&stringField)
+	assert.Equal(t, "foobar", stringField)
+}
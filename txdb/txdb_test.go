@@ -0,0 +1,129 @@
+package txdb
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"testing"
+
+	fixtures "github.com/AreaHQ/go-fixtures"
+	"github.com/stretchr/testify/assert"
+	// Driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var testSQLiteDb = "/tmp/fixtures_txdb_testdb.sqlite"
+
+var testSchema = `
+CREATE TABLE some_table(
+  id INT PRIMARY KEY NOT NULL,
+  string_field VARCHAR(50) NOT NULL
+);
+`
+
+var testData = `
+---
+- table: 'some_table'
+  pk:
+    id: 1
+  fields:
+    string_field: 'foobar'
+`
+
+var testDataUpdate = `
+---
+- table: 'some_table'
+  pk:
+    id: 1
+  fields:
+    string_field: 'updated'
+- table: 'some_table'
+  pk:
+    id: 2
+  fields:
+    string_field: 'inserted'
+`
+
+func TestTxDBRollsBackOnCloseSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Load the fixture into the real database once
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(testSchema)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = fixtures.Load([]byte(testData), db, "sqlite")
+	assert.Nil(t, err)
+
+	Register("txdb_sqlite", "sqlite3", testSQLiteDb)
+
+	var (
+		count       int
+		stringField string
+	)
+
+	// Change the data inside a txdb connection
+	txDB, err := sql.Open("txdb_sqlite", "first")
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = fixtures.Load([]byte(testDataUpdate), txDB, "sqlite")
+	assert.Nil(t, err)
+	txDB.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+	assert.Equal(t, 2, count)
+	txDB.QueryRow("SELECT string_field FROM some_table WHERE id = 1").Scan(&stringField)
+	assert.Equal(t, "updated", stringField)
+
+	// Failed loads are rolled back to their savepoint only
+	err = fixtures.Load([]byte(`- table: 'missing_table'
+  pk:
+    id: 1
+`), txDB, "sqlite")
+	assert.NotNil(t, err)
+	txDB.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+	assert.Equal(t, 2, count)
+	assert.Nil(t, txDB.Close())
+
+	// A new connection sees a clean copy of the fixture
+	txDB, err = sql.Open("txdb_sqlite", "second")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer txDB.Close()
+	txDB.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+	assert.Equal(t, 1, count)
+	txDB.QueryRow("SELECT string_field FROM some_table WHERE id = 1").Scan(&stringField)
+	assert.Equal(t, "foobar", stringField)
+
+	// Prepared statements and rows work too
+	stmt, err := txDB.Prepare("SELECT id, string_field FROM some_table WHERE id = ?")
+	assert.Nil(t, err)
+	rows, err := stmt.Query(1)
+	assert.Nil(t, err)
+	var id int
+	for rows.Next() {
+		assert.Nil(t, rows.Scan(&id, &stringField))
+	}
+	assert.Nil(t, rows.Err())
+	rows.Close()
+	stmt.Close()
+	assert.Equal(t, 1, id)
+	assert.Equal(t, "foobar", stringField)
+
+	// The real database is closed with the last transaction
+	drv := txDB.Driver().(*txDriver)
+	assert.NotNil(t, drv.db)
+	assert.Nil(t, txDB.Close())
+	assert.Nil(t, drv.db)
+}
@@ -0,0 +1,237 @@
+package fixtures
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// UndoEntry records a single row change made while loading a fixture. For
+// inserted rows only the primary key is kept, for updated rows Fields holds
+// the previous values of every updated column.
+type UndoEntry struct {
+	Table    string                 `yaml:"table"`
+	Schema   string                 `yaml:"schema,omitempty"`
+	PK       map[string]interface{} `yaml:"pk"`
+	Fields   map[string]interface{} `yaml:"fields,omitempty"`
+	Inserted bool                   `yaml:"inserted,omitempty"`
+}
+
+// UndoLog records the changes made by one or more fixture loads, in the order
+// they were made
+type UndoLog struct {
+	Entries []UndoEntry
+}
+
+// RecordUndo makes Load append the changes it commits to the undo log: the
+// primary keys of inserted rows and the previous values of updated rows
+func RecordUndo(log *UndoLog) Option {
+	return func(o *options) {
+		o.undo = log
+	}
+}
+
+// ReadUndoFile reads an undo log written by WriteFile
+func ReadUndoFile(filename string) (*UndoLog, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, NewFileError(filename, err)
+	}
+
+	log := new(UndoLog)
+	if err := yaml.Unmarshal(data, &log.Entries); err != nil {
+		return nil, NewFileError(filename, err)
+	}
+
+	// Saved text that looks like fixture syntax is written as a literal
+	for _, entry := range log.Entries {
+		for _, values := range []map[string]interface{}{entry.PK, entry.Fields} {
+			for column, value := range values {
+				if tag, s, ok := tagValue(value); ok && tag == "literal" {
+					values[column] = s
+				}
+			}
+		}
+	}
+	return log, nil
+}
+
+// WriteFile writes the undo log to a YAML file in the fixture format, with
+// inserted rows flagged by an inserted key
+func (log *UndoLog) WriteFile(filename string) error {
+	entries := make([]UndoEntry, len(log.Entries))
+	for i, entry := range log.Entries {
+		entries[i] = UndoEntry{
+			Table:    entry.Table,
+			Schema:   entry.Schema,
+			PK:       make(map[string]interface{}),
+			Inserted: entry.Inserted,
+		}
+		for column, value := range entry.PK {
+			entries[i].PK[column] = dumpValue(value)
+		}
+		if entry.Fields != nil {
+			entries[i].Fields = make(map[string]interface{})
+			for column, value := range entry.Fields {
+				entries[i].Fields[column] = dumpValue(value)
+			}
+		}
+	}
+
+	data, err := yaml.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// Undo restores the database to its state before the logged changes. Entries
+// are replayed in reverse order inside a single transaction, so rows are
+// deleted before the rows they depend on.
+func Undo(log *UndoLog, db *sql.DB, driver string) error {
+	// Begin a transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for i := len(log.Entries) - 1; i >= 0; i-- {
+		if err := log.Entries[i].revert(tx, driver); err != nil {
+			tx.Rollback() // rollback the transaction
+			return err
+		}
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		tx.Rollback() // rollback the transaction
+		return err
+	}
+
+	return nil
+}
+
+// revert deletes an inserted row or restores an updated row. The saved
+// values are sent as they are, they are database values and not fixture
+// syntax, so a saved 'TODAY' or 'NOW()' string is restored as that string.
+func (entry *UndoEntry) revert(tx *sql.Tx, driver string) error {
+	table := quoteTable(driver, entry.Table)
+	if entry.Schema != "" {
+		table = quoteIdentifier(driver, entry.Schema) + "." + quoteIdentifier(driver, entry.Table)
+	}
+
+	n := 0
+	var values []interface{}
+	assignments := func(columns map[string]interface{}) []string {
+		names := make([]string, 0, len(columns))
+		for column := range columns {
+			names = append(names, column)
+		}
+		sort.Strings(names)
+
+		assigned := make([]string, len(names))
+		for i, column := range names {
+			assigned[i] = fmt.Sprintf("%s = %s", quoteIdentifier(driver, column), placeholder(driver, nil, &n))
+			values = append(values, columns[column])
+		}
+		return assigned
+	}
+
+	if entry.Inserted {
+		deleteQuery := fmt.Sprintf(
+			`DELETE FROM %s WHERE %s`,
+			table,
+			strings.Join(assignments(entry.PK), " AND "),
+		)
+		_, err := tx.Exec(deleteQuery, values...)
+		return err
+	}
+
+	if len(entry.Fields) == 0 {
+		// Nothing but the primary key was updated
+		return nil
+	}
+
+	updateQuery := fmt.Sprintf(
+		`UPDATE %s SET %s WHERE %s`,
+		table,
+		strings.Join(assignments(entry.Fields), ", "),
+		strings.Join(assignments(entry.PK), " AND "),
+	)
+	_, err := tx.Exec(updateQuery, values...)
+	return err
+}
+
+// undoKey returns the key an undo entry finds a loaded row by. Raw SQL
+// expressions in the key would select other rows, or none, when the entry is
+// reverted, so the primary key they selected is read back instead.
+func undoKey(q queryer, driver string, row *Row) (map[string]interface{}, error) {
+	for _, value := range row.key() {
+		if _, ok := value.(SQL); !ok {
+			continue
+		}
+		key, err := selectKey(q, driver, row)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the key of %s for undo: %s", row.tableName(), err)
+		}
+		if len(key) == 0 {
+			return nil, fmt.Errorf("Table %s has no primary key, the row can not be undone", row.tableName())
+		}
+		return key, nil
+	}
+	return row.key(), nil
+}
+
+// selectPreImage selects the current values of the columns a fixture row is
+// about to update
+func selectPreImage(tx *sql.Tx, driver string, row *Row) (*UndoEntry, error) {
+	key, err := undoKey(tx, driver, row)
+	if err != nil {
+		return nil, err
+	}
+	entry := &UndoEntry{
+		Table:  row.Table,
+		Schema: row.Schema,
+		PK:     key,
+		Fields: make(map[string]interface{}),
+	}
+
+	var columns []string
+	for _, column := range row.updateColumns {
+		if _, ok := row.key()[column]; !ok {
+			columns = append(columns, column)
+		}
+	}
+	if len(columns) == 0 {
+		return entry, nil
+	}
+
+	escapedColumns := make([]string, len(columns))
+	for i, column := range columns {
+		escapedColumns[i] = quoteIdentifier(driver, column)
+	}
+	selectQuery := fmt.Sprintf(
+		`SELECT %s FROM %s WHERE %s`,
+		strings.Join(escapedColumns, ", "),
+		row.GetTable(driver),
+		row.GetWhere(driver, 0),
+	)
+
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := tx.QueryRow(selectQuery, row.GetPKValues()...).Scan(dest...); err != nil {
+		return nil, err
+	}
+	for i, column := range columns {
+		entry.Fields[column] = values[i]
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,199 @@
+package fixtures
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	// Driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var testUndoFile = "/tmp/fixtures_test.undo.yml"
+
+func TestUndoWorksWithValidDataSQLite(t *testing.T) {
+	// Delete the test database and undo file
+	os.Remove(testSQLiteDb)
+	os.Remove(testUndoFile)
+	defer os.Remove(testUndoFile)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a test schema
+	_, err = db.Exec(testSchemaSQLite)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Insert a row the fixture is going to update
+	_, err = db.Exec(`INSERT INTO some_table(id, string_field, boolean_field) VALUES(1, 'original', 0)`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	before, err := Dump(db, "sqlite", "some_table", "other_table", "join_table", "string_key_table")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load the fixture twice, recording an undo log
+	undo := new(UndoLog)
+	err = Load([]byte(testData), db, "sqlite", RecordUndo(undo))
+	assert.Nil(t, err)
+	err = Load([]byte(testData), db, "sqlite", RecordUndo(undo))
+	assert.Nil(t, err)
+
+	// The first load updated one row and inserted three, the second one
+	// updated all of them
+	assert.Equal(t, 8, len(undo.Entries))
+	assert.False(t, undo.Entries[0].Inserted)
+	assert.Equal(t, []byte("original"), undo.Entries[0].Fields["string_field"])
+	assert.True(t, undo.Entries[1].Inserted)
+	assert.Equal(t, map[string]interface{}{"id": 2}, undo.Entries[1].PK)
+	assert.False(t, undo.Entries[4].Inserted)
+
+	// Write the undo log to a file and read it back
+	err = undo.WriteFile(testUndoFile)
+	assert.Nil(t, err)
+	undo, err = ReadUndoFile(testUndoFile)
+	assert.Nil(t, err)
+	assert.Equal(t, 8, len(undo.Entries))
+
+	// Undoing restores the database to its state before the loads
+	err = Undo(undo, db, "sqlite")
+	assert.Nil(t, err)
+	after, err := Dump(db, "sqlite", "some_table", "other_table", "join_table", "string_key_table")
+	assert.Nil(t, err)
+	assert.Equal(t, string(before), string(after))
+
+	// Failed loads are not recorded
+	undo = new(UndoLog)
+	err = Load([]byte(`- table: 'missing_table'
+  pk:
+    id: 1
+`), db, "sqlite", RecordUndo(undo))
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(undo.Entries))
+
+	// Missing undo files can not be read
+	_, err = ReadUndoFile("bad_file")
+	assert.EqualError(t, err, "Error loading file bad_file: open bad_file: no such file or directory")
+}
+
+func TestUndoRestoresValuesLiterallySQLite(t *testing.T) {
+	// Delete the test database and undo file
+	os.Remove(testSQLiteDb)
+	os.Remove(testUndoFile)
+	defer os.Remove(testUndoFile)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Saved values which look like fixture syntax
+	_, err = db.Exec(`
+		CREATE TABLE notes(id INT PRIMARY KEY, a TEXT, b TEXT, c TEXT, d TEXT, e TEXT);
+		INSERT INTO notes VALUES(1, 'TODAY', 'ON_INSERT_NOW()', 'NOW()', 'NOW-3d', 'FAKE(name)');
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	before, err := Dump(db, "sqlite", "notes")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	undo := new(UndoLog)
+	err = Load([]byte(`
+- table: 'notes'
+  pk:
+    id: 1
+  fields:
+    a: 'x'
+    b: 'x'
+    c: 'x'
+    d: 'x'
+    e: 'x'
+`), db, "sqlite", RecordUndo(undo))
+	assert.Nil(t, err)
+
+	// Replaying from a file restores them as the same strings
+	err = undo.WriteFile(testUndoFile)
+	assert.Nil(t, err)
+	undo, err = ReadUndoFile(testUndoFile)
+	assert.Nil(t, err)
+	err = Undo(undo, db, "sqlite")
+	assert.Nil(t, err)
+	after, err := Dump(db, "sqlite", "notes")
+	assert.Nil(t, err)
+	assert.Equal(t, string(before), string(after))
+}
+
+func TestUndoReadsBackSQLKeysSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a test schema
+	_, err = db.Exec(testSchemaSQLite)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load a row keyed by an expression twice, inserting and then updating it
+	fixture := []byte(`
+- table: 'some_table'
+  pk:
+    id: !sql "1 + 1"
+  fields:
+    string_field: 'computed key'
+    boolean_field: true
+`)
+	undo := new(UndoLog)
+	err = Load(fixture, db, "sqlite", RecordUndo(undo))
+	assert.Nil(t, err)
+	err = Load(fixture, db, "sqlite", RecordUndo(undo))
+	assert.Nil(t, err)
+
+	// The undo log holds the key the expression selected
+	assert.Equal(t, 2, len(undo.Entries))
+	assert.Equal(t, map[string]interface{}{"id": int64(2)}, undo.Entries[0].PK)
+	assert.Equal(t, map[string]interface{}{"id": int64(2)}, undo.Entries[1].PK)
+
+	err = Undo(undo, db, "sqlite")
+	assert.Nil(t, err)
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM some_table`).Scan(&count)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+}
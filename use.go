@@ -0,0 +1,37 @@
+package fixtures
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"testing"
+)
+
+// Use loads YAML fixture files for the duration of a test. Loading errors
+// fail the test. When the test and its subtests finish, rows inserted by the
+// fixtures are deleted and rows they updated are restored to their previous
+// values.
+func Use(t testing.TB, db *sql.DB, driver string, filenames []string, opts ...Option) {
+	t.Helper()
+
+	// Register the cleanup first, so files loaded before a failure are reverted
+	log := new(UndoLog)
+	t.Cleanup(func() {
+		if err := Undo(log, db, driver); err != nil {
+			t.Errorf("Error reverting fixtures: %s", err)
+		}
+	})
+
+	// Share labels across the files, unless the caller records the result,
+	// a single reference time and the SEQ() counters
+	opts = append([]Option{RecordResult(new(Result))}, opts...)
+	opts = append(opts, RecordUndo(log), fixedClock(newOptions(opts).clock()), sharedSequences(make(map[string]int64)))
+	for _, filename := range filenames {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(NewFileError(filename, err))
+		}
+		if err := load(filename, data, db, driver, newOptions(opts)); err != nil {
+			t.Fatal(NewFileError(filename, err))
+		}
+	}
+}
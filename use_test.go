@@ -0,0 +1,131 @@
+package fixtures
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	// Driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestUseRevertsFixturesSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a test schema
+	_, err = db.Exec(testSchemaSQLite)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Insert a row the fixtures are going to update
+	_, err = db.Exec(`INSERT INTO some_table(id, string_field, boolean_field) VALUES(1, 'original', 0)`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var (
+		count        int
+		stringField  string
+		booleanField bool
+	)
+
+	t.Run("Use", func(t *testing.T) {
+		Use(t, db, "sqlite", fixtureFiles)
+
+		// Check the fixtures have been loaded
+		db.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+		assert.Equal(t, 1, count)
+		db.QueryRow("SELECT COUNT(*) FROM other_table").Scan(&count)
+		assert.Equal(t, 1, count)
+		db.QueryRow("SELECT COUNT(*) FROM join_table").Scan(&count)
+		assert.Equal(t, 1, count)
+		db.QueryRow("SELECT string_field FROM some_table").Scan(&stringField)
+		assert.Equal(t, "foobar", stringField)
+	})
+
+	// Check inserted rows have been deleted and updated rows restored
+	db.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+	assert.Equal(t, 1, count)
+	db.QueryRow("SELECT COUNT(*) FROM other_table").Scan(&count)
+	assert.Equal(t, 0, count)
+	db.QueryRow("SELECT COUNT(*) FROM join_table").Scan(&count)
+	assert.Equal(t, 0, count)
+	db.QueryRow("SELECT COUNT(*) FROM string_key_table").Scan(&count)
+	assert.Equal(t, 0, count)
+	db.QueryRow("SELECT string_field, boolean_field FROM some_table").Scan(&stringField, &booleanField)
+	assert.Equal(t, "original", stringField)
+	assert.Equal(t, false, booleanField)
+	db.QueryRow("SELECT COUNT(*) FROM some_table WHERE updated_at IS NULL").Scan(&count)
+	assert.Equal(t, 1, count)
+}
+
+func TestUseFailsWithABadFileSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a test schema
+	_, err = db.Exec(testSchemaSQLite)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var badList = []string{
+		fixtureFile,
+		"bad_file",
+	}
+
+	tb := runFakeTB(func(tb *fakeTB) {
+		Use(tb, db, "sqlite", badList)
+	})
+	assert.True(t, tb.failed)
+	assert.Equal(t, "Error loading file bad_file: open bad_file: no such file or directory", tb.message)
+
+	// The file loaded before the failure has been reverted
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+	assert.Equal(t, 0, count)
+
+	// Processing errors mention the file too
+	_, err = db.Exec(`DROP TABLE join_table`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tb = runFakeTB(func(tb *fakeTB) {
+		Use(tb, db, "sqlite", fixtureFiles)
+	})
+	assert.True(t, tb.failed)
+	assert.Equal(t, "Error loading file fixtures/test_fixtures2.yml: "+
+		"Error loading row 2: no such table: join_table", tb.message)
+	db.QueryRow("SELECT COUNT(*) FROM some_table").Scan(&count)
+	assert.Equal(t, 0, count)
+	db.QueryRow("SELECT COUNT(*) FROM other_table").Scan(&count)
+	assert.Equal(t, 0, count)
+}
@@ -0,0 +1,203 @@
+package fixtures
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ValueFunc computes a field value from a function call in a fixture, such
+// as RANDOM_INT(1, 10). OnInsert and OnUpdate tell whether the column is set
+// when the row is inserted, updated or both. Volatile functions return a
+// different value on every call, so verification only checks the column is
+// not NULL.
+type ValueFunc struct {
+	OnInsert bool
+	OnUpdate bool
+	Volatile bool
+	Call     func(ctx *ValueContext, args []string) (interface{}, error)
+}
+
+// ValueContext describes the row a value function is called for
+type ValueContext struct {
+	// Now is the reference time of the load, see Clock
+	Now time.Time
+
+	// Table is the schema-qualified table of the row
+	Table string
+
+	// PK is the primary key, or the match columns, of the row
+	PK map[string]interface{}
+
+	// Column is the column the value is computed for
+	Column string
+
+	// Locale is the locale of fake data, see FakeLocale
+	Locale string
+
+	source *valueSource
+	rand   *rand.Rand
+}
+
+// Rand returns a source of random numbers that is the same every time the
+// fixture is loaded with the same seed, see FakeSeed. It is only seeded for
+// functions calling it.
+func (ctx *ValueContext) Rand() *rand.Rand {
+	if ctx.rand == nil {
+		source := ctx.source
+		if source == nil {
+			source = newValueSource(newOptions(nil))
+		}
+		ctx.rand = source.columnRand(ctx.Table, ctx.PK, ctx.Column)
+	}
+	return ctx.rand
+}
+
+var (
+	valueFuncsMu sync.RWMutex
+	valueFuncs   = map[string]ValueFunc{
+		"ON_INSERT_NOW": {OnInsert: true, Call: callNow},
+		"ON_UPDATE_NOW": {OnUpdate: true, Call: callNow},
+		"NOW":           {OnInsert: true, OnUpdate: true, Call: callNow},
+		"UUID":          {OnInsert: true, Volatile: true, Call: callUUID},
+		"SEQ":           {OnInsert: true, Volatile: true, Call: callSeq},
+		"RANDOM_INT":    {OnInsert: true, OnUpdate: true, Volatile: true, Call: callRandomInt},
+		"ENV":           {OnInsert: true, OnUpdate: true, Call: callEnv},
+		"FAKE":          {OnInsert: true, OnUpdate: true, Call: callFake},
+	}
+)
+
+// valueFuncCall matches a value function call such as SEQ(orders)
+var valueFuncCall = regexp.MustCompile(`^([A-Z][A-Z0-9_]*)\((.*)\)$`)
+
+// RegisterValueFunc makes a value function available to fixtures under a
+// name, replacing any function registered under the same name:
+//
+//	fixtures.RegisterValueFunc("BCRYPT", fixtures.ValueFunc{
+//		OnInsert: true,
+//		OnUpdate: true,
+//		Volatile: true,
+//		Call: func(ctx *fixtures.ValueContext, args []string) (interface{}, error) {
+//			return bcrypt.GenerateFromPassword([]byte(args[0]), bcrypt.MinCost)
+//		},
+//	})
+func RegisterValueFunc(name string, fn ValueFunc) {
+	valueFuncsMu.Lock()
+	defer valueFuncsMu.Unlock()
+	valueFuncs[name] = fn
+}
+
+// parseValueFunc returns the registered function and arguments of a value
+// function call
+func parseValueFunc(v interface{}) (*ValueFunc, []string, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, nil, false
+	}
+	match := valueFuncCall.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return nil, nil, false
+	}
+
+	valueFuncsMu.RLock()
+	fn, ok := valueFuncs[match[1]]
+	valueFuncsMu.RUnlock()
+	if !ok {
+		return nil, nil, false
+	}
+	return &fn, splitArgs(match[2]), true
+}
+
+// splitArgs splits function arguments on commas outside quotes, and removes
+// the quotes around them
+func splitArgs(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+
+	var args []string
+	var quote rune
+	start := 0
+	for i, c := range s {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+		case quote == 0 && c == ',':
+			args = append(args, unquoteArg(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(args, unquoteArg(s[start:]))
+}
+
+// unquoteArg trims an argument and removes the quotes around it
+func unquoteArg(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// callNow returns the reference time of the load
+func callNow(ctx *ValueContext, args []string) (interface{}, error) {
+	return ctx.Now, nil
+}
+
+// callUUID returns a random UUID
+func callUUID(ctx *ValueContext, args []string) (interface{}, error) {
+	return newUUID()
+}
+
+// callSeq returns the next value of the named sequence, starting at 1 in
+// every load
+func callSeq(ctx *ValueContext, args []string) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("SEQ expects a sequence name")
+	}
+	if ctx.source == nil {
+		ctx.source = newValueSource(newOptions(nil))
+	}
+	ctx.source.sequences[args[0]]++
+	return ctx.source.sequences[args[0]], nil
+}
+
+// callRandomInt returns a random integer between its arguments, inclusive,
+// from the seeded random numbers of the row
+func callRandomInt(ctx *ValueContext, args []string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("RANDOM_INT expects a minimum and a maximum")
+	}
+	min, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	max, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if max < min {
+		return nil, fmt.Errorf("RANDOM_INT maximum %d is less than minimum %d", max, min)
+	}
+	// The size of the range wraps around to 0 for every int64
+	size := uint64(max) - uint64(min) + 1
+	if size == 0 {
+		return nil, fmt.Errorf("RANDOM_INT range %d-%d covers every int64", min, max)
+	}
+	return int64(uint64(min) + ctx.Rand().Uint64()%size), nil
+}
+
+// callEnv returns an environment variable
+func callEnv(ctx *ValueContext, args []string) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("ENV expects a variable name")
+	}
+	return os.Getenv(args[0]), nil
+}
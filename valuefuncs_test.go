@@ -0,0 +1,147 @@
+package fixtures
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseValueFunc(t *testing.T) {
+	_, args, ok := parseValueFunc(`RANDOM_INT(1, 10)`)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"1", "10"}, args)
+
+	_, args, ok = parseValueFunc(`ENV("A, B")`)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"A, B"}, args)
+
+	_, args, ok = parseValueFunc(`NOW()`)
+	assert.True(t, ok)
+	assert.Nil(t, args)
+
+	// Unknown functions and other values are plain values
+	_, _, ok = parseValueFunc(`UNKNOWN()`)
+	assert.False(t, ok)
+	_, _, ok = parseValueFunc(`hello (world)`)
+	assert.False(t, ok)
+	_, _, ok = parseValueFunc(123)
+	assert.False(t, ok)
+}
+
+func TestRowInitCallsValueFuncs(t *testing.T) {
+	os.Setenv("FIXTURES_TEST_ENV", "secret")
+	defer os.Unsetenv("FIXTURES_TEST_ENV")
+	RegisterValueFunc("TEST_UPDATE_ONLY", ValueFunc{
+		OnUpdate: true,
+		Call: func(ctx *ValueContext, args []string) (interface{}, error) {
+			return args[0] + "!", nil
+		},
+	})
+
+	row := &Row{
+		Table: "some_table",
+		PK:    map[string]interface{}{"id": 1},
+		Fields: map[string]interface{}{
+			"a_seq":    "SEQ(test_row_init)",
+			"b_env":    `ENV("FIXTURES_TEST_ENV")`,
+			"c_now":    "NOW()",
+			"d_random": "RANDOM_INT(5, 5)",
+			"e_update": "TEST_UPDATE_ONLY(x)",
+		},
+	}
+	assert.Nil(t, row.Init())
+
+	assert.Equal(t, []string{`"id"`, `"a_seq"`, `"b_env"`, `"c_now"`, `"d_random"`}, row.GetInsertColumns())
+	assert.Equal(t, []string{`"id"`, `"b_env"`, `"c_now"`, `"d_random"`, `"e_update"`}, row.GetUpdateColumns())
+	assert.Equal(t, 5, row.GetInsertColumnsLength())
+	assert.Equal(t, 5, row.GetUpdateColumnsLength())
+
+	values := row.GetInsertValues()
+	assert.Equal(t, int64(1), values[1])
+	assert.Equal(t, "secret", values[2])
+	assert.IsType(t, time.Time{}, values[3])
+	assert.Equal(t, int64(5), values[4])
+	assert.Equal(t, "x!", row.GetUpdateValues()[4])
+
+	// Sequences continue across rows
+	assert.Nil(t, row.Init())
+	assert.Equal(t, int64(2), row.GetInsertValues()[1])
+
+	// Errors name the column
+	row.Fields = map[string]interface{}{"number": "RANDOM_INT(10, 1)"}
+	assert.EqualError(t, row.Init(), "Error computing number: RANDOM_INT maximum 1 is less than minimum 10")
+}
+
+func TestValueFuncsCanReturnSQL(t *testing.T) {
+	RegisterValueFunc("TEST_SQL_POINT", ValueFunc{
+		OnInsert: true,
+		Call: func(ctx *ValueContext, args []string) (interface{}, error) {
+			return SQL("ST_MakePoint(" + args[0] + ", " + args[1] + ")"), nil
+		},
+	})
+
+	row := &Row{
+		Table:  "places",
+		PK:     map[string]interface{}{"id": 1},
+		Fields: map[string]interface{}{"location": "TEST_SQL_POINT(1, 2)"},
+	}
+	assert.Nil(t, row.Init())
+	assert.Equal(t, []string{"$1", "ST_MakePoint(1, 2)"}, row.GetInsertPlaceholders("postgres"))
+	assert.Equal(t, []interface{}{1}, row.GetInsertValues())
+}
+
+func TestRandomIntUsesTheSeed(t *testing.T) {
+	ctx := func(seed int64) *ValueContext {
+		return &ValueContext{
+			Table:  "some_table",
+			PK:     map[string]interface{}{"id": 1},
+			Column: "number",
+			source: newValueSource(newOptions([]Option{FakeSeed(seed)})),
+		}
+	}
+
+	// The same seed gives the same numbers
+	a, err := callRandomInt(ctx(1), []string{"1", "1000000"})
+	assert.Nil(t, err)
+	b, err := callRandomInt(ctx(1), []string{"1", "1000000"})
+	assert.Nil(t, err)
+	assert.Equal(t, a, b)
+
+	// Ranges wider than the largest int64 work, but not every int64
+	c, err := callRandomInt(ctx(1), []string{"0", "9223372036854775807"})
+	assert.Nil(t, err)
+	assert.True(t, c.(int64) >= 0)
+	c, err = callRandomInt(ctx(1), []string{"-9223372036854775808", "-9223372036854775808"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(math.MinInt64), c)
+	_, err = callRandomInt(ctx(1), []string{"-9223372036854775808", "9223372036854775807"})
+	assert.EqualError(t, err, "RANDOM_INT range -9223372036854775808-9223372036854775807 covers every int64")
+
+	// Functions not using random numbers don't seed them
+	now := ctx(1)
+	_, err = callNow(now, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, now.rand)
+}
+
+func TestSeqCountsPerLoad(t *testing.T) {
+	next := func(source *valueSource) interface{} {
+		value, err := callSeq(&ValueContext{source: source}, []string{"test_seq_per_load"})
+		assert.Nil(t, err)
+		return value
+	}
+
+	// Every load starts its sequences at 1
+	first := newValueSource(newOptions(nil))
+	assert.Equal(t, int64(1), next(first))
+	assert.Equal(t, int64(2), next(first))
+	assert.Equal(t, int64(1), next(newValueSource(newOptions(nil))))
+
+	// Loads sharing the counters continue them
+	shared := []Option{sharedSequences(make(map[string]int64))}
+	assert.Equal(t, int64(1), next(newValueSource(newOptions(shared))))
+	assert.Equal(t, int64(2), next(newValueSource(newOptions(shared))))
+}
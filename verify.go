@@ -0,0 +1,674 @@
+package fixtures
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ColumnDiff describes a single column whose value differs from the fixture
+type ColumnDiff struct {
+	Column   string
+	Expected interface{}
+	Actual   interface{}
+}
+
+// RowDiff describes a fixture row that does not match the database. Extra is
+// set for rows found in the database but missing from the fixture, which are
+// only reported in strict mode.
+type RowDiff struct {
+	Row     int
+	Table   string
+	PK      string
+	Missing bool
+	Extra   bool
+	Columns []ColumnDiff
+}
+
+// VerificationError is returned when the database does not match a fixture
+type VerificationError struct {
+	Diffs []RowDiff
+}
+
+// Error returns a readable per-row, per-column diff
+func (e *VerificationError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Database does not match fixture (%d rows differ):", len(e.Diffs))
+	for _, diff := range e.Diffs {
+		if diff.Extra {
+			fmt.Fprintf(&buf, "\n  unexpected row, %s (%s)", diff.Table, diff.PK)
+			continue
+		}
+		fmt.Fprintf(&buf, "\n  row %d, %s (%s):", diff.Row, diff.Table, diff.PK)
+		if diff.Missing {
+			buf.WriteString(" row not found")
+			continue
+		}
+		for _, col := range diff.Columns {
+			fmt.Fprintf(
+				&buf,
+				"\n    %s: expected %s, got %s",
+				col.Column,
+				formatExpected(col.Expected),
+				formatValue(col.Actual),
+			)
+		}
+	}
+	return buf.String()
+}
+
+// Verify checks that every row of a YAML fixture exists in the database with
+// the same field values. Rows are selected by primary key and values are
+// compared after normalization, so int vs int64, []byte vs string and
+// timestamps in different time zones compare equal. ON_INSERT_NOW() fields
+// must hold a timestamp close to the current time and ON_UPDATE_NOW() fields
+// must either hold one or be NULL (see TimeTolerance). Use Strict to also
+// report unexpected rows and IgnoreColumns to skip volatile columns. A
+// *VerificationError is returned if anything differs.
+func Verify(data []byte, db *sql.DB, driver string, opts ...Option) error {
+	return verify("", data, db, driver, newOptions(opts))
+}
+
+// verify checks the database against a YAML fixture read from filename,
+// which is empty for data passed to Verify
+func verify(filename string, data []byte, db *sql.DB, driver string, o *options) error {
+	// Execute the fixture template
+	data, err := expandTemplate(filename, data, o.clock(), o)
+	if err != nil {
+		return err
+	}
+
+	// Unmarshal the YAML data into a []Row slice
+	document, err := parseDocument(data, fixtureDir(filename), o)
+	if err != nil {
+		return err
+	}
+
+	return verifyRows(document.Rows, document.Defaults, db, driver, o)
+}
+
+// VerifyFile checks the database against a YAML fixture file
+func VerifyFile(filename string, db *sql.DB, driver string, opts ...Option) error {
+	// Read fixture data from the file
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return NewFileError(filename, err)
+	}
+
+	return verify(filename, data, db, driver, newOptions(opts))
+}
+
+// Assert fails the test with a readable diff if the database does not match
+// the YAML fixture
+func Assert(t testing.TB, data []byte, db *sql.DB, driver string, opts ...Option) {
+	t.Helper()
+	if err := Verify(data, db, driver, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// AssertFile fails the test with a readable diff if the database does not
+// match the YAML fixture file
+func AssertFile(t testing.TB, filename string, db *sql.DB, driver string, opts ...Option) {
+	t.Helper()
+	if err := VerifyFile(filename, db, driver, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// verifyRows compares fixture rows with the database
+func verifyRows(rows []Row, defaults tableDefaults, db *sql.DB, driver string, o *options) error {
+	var diffs []RowDiff
+
+	// Database rows matched by fixture rows without a primary key
+	var matched []Row
+
+	// Labelled rows verified so far, for references, and their derived keys
+	verified := make(labels)
+	derived := make(labelKeys)
+
+	// Value functions and fake data are computed as when loading
+	now := o.clock()
+	source := newValueSource(o)
+
+	for i := range rows {
+		if err := inheritFields(&rows[i], []tableDefaults{defaults}, verified); err != nil {
+			return NewProcessingError(i+1, err)
+		}
+		if err := setLabelKey(db, driver, &rows[i], o, derived, nil); err != nil {
+			return NewProcessingError(i+1, err)
+		}
+		if err := resolveRefs(db, driver, &rows[i], verified); err != nil {
+			return NewProcessingError(i+1, err)
+		}
+		rows[i].now = now
+		rows[i].source = source
+		if err := rows[i].Init(); err != nil {
+			return NewProcessingError(i+1, err)
+		}
+
+		var diff *RowDiff
+		var match *Row
+		var err error
+		if len(rows[i].key()) == 0 {
+			diff, match, err = verifyRowByFields(db, driver, rows[i], o, matched)
+		} else {
+			diff, err = verifyRow(db, driver, rows[i], o)
+			if err == nil && len(rows[i].PK) == 0 && (diff == nil || !diff.Missing) {
+				// Rows found by their match columns are listed by primary key
+				var key map[string]interface{}
+				key, err = selectKey(db, driver, &rows[i])
+				if len(key) > 0 {
+					match = &Row{Table: rows[i].Table, Schema: rows[i].Schema, PK: key}
+					match.Init()
+				}
+			}
+		}
+		if err != nil {
+			return NewProcessingError(i+1, err)
+		}
+		if diff != nil {
+			diff.Row = i + 1
+			diffs = append(diffs, *diff)
+		}
+
+		key := rows[i].PK
+		if match != nil {
+			matched = append(matched, *match)
+			key = match.PK
+		}
+		if err := verified.add(&rows[i], key); err != nil {
+			return NewProcessingError(i+1, err)
+		}
+	}
+
+	if o.strict {
+		extras, err := findExtraRows(db, driver, append(rows, matched...))
+		if err != nil {
+			return err
+		}
+		diffs = append(diffs, extras...)
+	}
+
+	if len(diffs) > 0 {
+		return &VerificationError{Diffs: diffs}
+	}
+	return nil
+}
+
+// verifyRow selects a single fixture row by primary key and compares it
+func verifyRow(db *sql.DB, driver string, row Row, o *options) (*RowDiff, error) {
+	columns := make([]string, 0, len(row.Fields))
+	for column := range row.Fields {
+		if o.isIgnored(&row, column) {
+			continue
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	selectColumns := "COUNT(*)"
+	if len(columns) > 0 {
+		escapedColumns := make([]string, len(columns))
+		for i, column := range columns {
+			escapedColumns[i] = quoteIdentifier(driver, column)
+		}
+		selectColumns = strings.Join(escapedColumns, ", ")
+	}
+	selectQuery := fmt.Sprintf(
+		`SELECT %s FROM %s WHERE %s`,
+		selectColumns,
+		row.GetTable(driver),
+		row.GetWhere(driver, 0),
+	)
+
+	diff := &RowDiff{Table: row.tableName(), PK: formatPK(row)}
+	actual := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range actual {
+		dest[i] = &actual[i]
+	}
+	if len(columns) == 0 {
+		var count int
+		dest = []interface{}{&count}
+	}
+
+	err := db.QueryRow(selectQuery, row.GetPKValues()...).Scan(dest...)
+	if err == sql.ErrNoRows {
+		diff.Missing = true
+		return diff, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		if *(dest[0].(*int)) == 0 {
+			diff.Missing = true
+			return diff, nil
+		}
+		return nil, nil
+	}
+
+	for i, column := range columns {
+		if !o.matches(&row, column, actual[i]) {
+			diff.Columns = append(diff.Columns, ColumnDiff{
+				Column:   column,
+				Expected: row.Fields[column],
+				Actual:   actual[i],
+			})
+		}
+	}
+
+	if len(diff.Columns) == 0 {
+		return nil, nil
+	}
+	return diff, nil
+}
+
+// verifyRowByFields looks for a database row matching every field of a fixture
+// row without a primary key, skipping rows already matched by others, and
+// returns it with its primary key
+func verifyRowByFields(db *sql.DB, driver string, row Row, o *options, matched []Row) (*RowDiff, *Row, error) {
+	pkColumns, err := primaryKeyColumns(db, driver, row.tableName())
+	if err != nil {
+		return nil, nil, err
+	}
+	columns := make([]string, 0, len(row.Fields))
+	for column := range row.Fields {
+		if o.isIgnored(&row, column) {
+			continue
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	selectColumns := append(append([]string{}, pkColumns...), columns...)
+	escapedColumns := make([]string, len(selectColumns))
+	for i, column := range selectColumns {
+		escapedColumns[i] = quoteIdentifier(driver, column)
+	}
+	if len(escapedColumns) == 0 {
+		// Any row matches a row without fields in a table without a key
+		escapedColumns = []string{"NULL"}
+		selectColumns = []string{""}
+	}
+	selectQuery := fmt.Sprintf(
+		`SELECT %s FROM %s`,
+		strings.Join(escapedColumns, ", "),
+		row.GetTable(driver),
+	)
+
+	dbRows, err := db.Query(selectQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer dbRows.Close()
+	for dbRows.Next() {
+		actual := make([]interface{}, len(selectColumns))
+		dest := make([]interface{}, len(selectColumns))
+		for i := range actual {
+			dest[i] = &actual[i]
+		}
+		if err := dbRows.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+		pkValues := actual[:len(pkColumns)]
+		if len(pkColumns) > 0 && containsPK(matched, pkColumns, pkValues) {
+			continue
+		}
+
+		found := true
+		for i, column := range columns {
+			if !o.matches(&row, column, actual[len(pkColumns)+i]) {
+				found = false
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		if len(pkColumns) == 0 {
+			return nil, nil, nil
+		}
+		match := Row{Table: row.Table, Schema: row.Schema, PK: make(map[string]interface{})}
+		for i, column := range pkColumns {
+			match.PK[column] = pkValues[i]
+		}
+		match.Init()
+		return nil, &match, nil
+	}
+	if err := dbRows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return &RowDiff{Table: row.tableName(), Missing: true}, nil, nil
+}
+
+// findExtraRows returns a diff for every database row of the fixture's
+// tables whose primary key is not listed in the fixture
+func findExtraRows(db *sql.DB, driver string, rows []Row) ([]RowDiff, error) {
+	// Group fixture rows by table, keeping the order tables first appear in
+	var tables []string
+	tableRows := make(map[string][]Row)
+	for _, row := range rows {
+		table := row.tableName()
+		if _, ok := tableRows[table]; !ok {
+			tables = append(tables, table)
+		}
+		tableRows[table] = append(tableRows[table], row)
+	}
+
+	var diffs []RowDiff
+	for _, table := range tables {
+		first := tableRows[table][0]
+		var pkColumns []string
+		for _, row := range tableRows[table] {
+			if len(row.PK) > 0 {
+				pkColumns = row.pkColumns
+				break
+			}
+		}
+		if len(pkColumns) == 0 {
+			// Only rows without a primary key, ask the database for it
+			var err error
+			pkColumns, err = primaryKeyColumns(db, driver, table)
+			if err != nil {
+				return nil, err
+			}
+			if len(pkColumns) == 0 {
+				return nil, fmt.Errorf("Table %s has no primary key", table)
+			}
+		}
+		escapedColumns := make([]string, len(pkColumns))
+		for i, column := range pkColumns {
+			escapedColumns[i] = quoteIdentifier(driver, column)
+		}
+		selectQuery := fmt.Sprintf(
+			`SELECT %s FROM %s ORDER BY %s`,
+			strings.Join(escapedColumns, ", "),
+			first.GetTable(driver),
+			strings.Join(escapedColumns, ", "),
+		)
+
+		dbRows, err := db.Query(selectQuery)
+		if err != nil {
+			return nil, err
+		}
+		for dbRows.Next() {
+			pkValues := make([]interface{}, len(pkColumns))
+			dest := make([]interface{}, len(pkColumns))
+			for i := range pkValues {
+				dest[i] = &pkValues[i]
+			}
+			if err := dbRows.Scan(dest...); err != nil {
+				dbRows.Close()
+				return nil, err
+			}
+			if !containsPK(tableRows[table], pkColumns, pkValues) {
+				extra := Row{
+					Table:  first.Table,
+					Schema: first.Schema,
+					PK:     make(map[string]interface{}),
+				}
+				for i, column := range pkColumns {
+					extra.PK[column] = pkValues[i]
+				}
+				extra.Init()
+				diffs = append(diffs, RowDiff{
+					Table: table,
+					PK:    formatPK(extra),
+					Extra: true,
+				})
+			}
+		}
+		if err := dbRows.Err(); err != nil {
+			dbRows.Close()
+			return nil, err
+		}
+		dbRows.Close()
+	}
+
+	return diffs, nil
+}
+
+// containsPK returns true if one of the rows has the given primary key values
+func containsPK(rows []Row, pkColumns []string, pkValues []interface{}) bool {
+	for _, row := range rows {
+		found := true
+		for i, column := range pkColumns {
+			if !valuesEqual(row.PK[column], pkValues[i]) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// matches compares the expected value of a fixture column with a database
+// value. Value functions returning times, such as NOW(), match timestamps
+// close to the current time and volatile ones, such as UUID(), any value but
+// NULL. ON_UPDATE_NOW() also matches NULL, for rows that have never been
+// updated. Other value functions match the value computed when the row was
+// initialised.
+func (o *options) matches(row *Row, column string, actual interface{}) bool {
+	expected := row.Fields[column]
+	if _, ok := expected.(SQL); ok {
+		// Raw SQL expressions are computed by the database
+		return actual != nil
+	}
+	if t, ok, err := parseRelativeTime(expected, o.clock()); ok {
+		return err == nil && o.withinTolerance(t, actual)
+	}
+	fn, args, ok := parseValueFunc(expected)
+	if !ok {
+		return valuesEqual(expected, actual)
+	}
+
+	if actual == nil {
+		// Rows that have never been updated have no ON_UPDATE_NOW() value
+		return !fn.OnInsert
+	}
+	if fn.Volatile {
+		return true
+	}
+	value, ok := row.computedValue(column)
+	if !ok {
+		var err error
+		if value, err = fn.Call(&ValueContext{Now: o.clock(), Table: row.tableName(), PK: row.key(), Column: column}, args); err != nil {
+			return false
+		}
+	}
+	expectedTime, ok := value.(time.Time)
+	if !ok {
+		return valuesEqual(value, actual)
+	}
+	return o.withinTolerance(expectedTime, actual)
+}
+
+// withinTolerance returns true if a database value is a timestamp within the
+// time tolerance of the expected time
+func (o *options) withinTolerance(expected time.Time, actual interface{}) bool {
+	t, ok := toTime(normalizeValue(actual))
+	if !ok {
+		return false
+	}
+	diff := expected.Sub(t)
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= o.timeTolerance
+}
+
+// formatPK returns a readable representation of the row's primary key
+func formatPK(row Row) string {
+	pks := make([]string, len(row.pkColumns))
+	for i, column := range row.pkColumns {
+		pks[i] = fmt.Sprintf("%s=%s", column, formatValue(row.key()[column]))
+	}
+	return strings.Join(pks, ", ")
+}
+
+// formatExpected returns a readable representation of a fixture value
+func formatExpected(v interface{}) string {
+	if _, _, ok := parseValueFunc(v); ok {
+		return v.(string)
+	}
+	return formatValue(v)
+}
+
+// formatValue returns a readable representation of a fixture or database value
+func formatValue(v interface{}) string {
+	switch v := normalizeValue(v).(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return strconv.Quote(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// timeLayouts are tried in order when comparing a string with a timestamp
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// normalizeValue converts database and YAML values to a small set of types:
+// nil, int64, float64, bool, string and time.Time (in UTC)
+func normalizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case Literal:
+		return string(v)
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case float32:
+		return normalizeValue(float64(v))
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v)
+		}
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC()
+	default:
+		return v
+	}
+}
+
+// valuesEqual compares an expected fixture value with a database value
+func valuesEqual(expected, actual interface{}) bool {
+	expected = normalizeValue(expected)
+	actual = normalizeValue(actual)
+
+	if expected == nil || actual == nil {
+		return expected == nil && actual == nil
+	}
+
+	switch e := expected.(type) {
+	case time.Time:
+		a, ok := toTime(actual)
+		return ok && e.Equal(a)
+	case bool:
+		a, ok := toBool(actual)
+		return ok && e == a
+	case int64:
+		switch a := actual.(type) {
+		case int64:
+			return e == a
+		case bool:
+			return (e == 1 && a) || (e == 0 && !a)
+		case string:
+			n, err := strconv.ParseFloat(a, 64)
+			return err == nil && float64(e) == n
+		}
+	case float64:
+		switch a := actual.(type) {
+		case float64:
+			return e == a
+		case string:
+			n, err := strconv.ParseFloat(a, 64)
+			return err == nil && e == n
+		}
+	case string:
+		switch a := actual.(type) {
+		case string:
+			return e == a
+		case time.Time:
+			t, ok := toTime(e)
+			return ok && t.Equal(a)
+		case bool:
+			b, ok := toBool(e)
+			return ok && b == a
+		case int64, float64:
+			return valuesEqual(actual, expected)
+		}
+	}
+
+	return false
+}
+
+// toTime converts a normalized value to a time.Time if possible
+func toTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// toBool converts a normalized value to a bool if possible
+func toBool(v interface{}) (bool, bool) {
+	switch v := v.(type) {
+	case bool:
+		return v, true
+	case int64:
+		return v != 0, v == 0 || v == 1
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+	return false, false
+}
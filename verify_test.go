@@ -0,0 +1,227 @@
+package fixtures
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	// Driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestVerifyWorksWithMatchingDataSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a test schema
+	_, err = db.Exec(testSchemaSQLite)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load the fixture
+	err = Load([]byte(testData), db, "sqlite")
+	assert.Nil(t, err)
+
+	// The database should now match the fixture
+	err = Verify([]byte(testData), db, "sqlite")
+	assert.Nil(t, err)
+	Assert(t, []byte(testData), db, "sqlite")
+
+	// Reload the fixture, the database should still match
+	err = LoadFile(fixtureFile, db, "sqlite")
+	assert.Nil(t, err)
+	AssertFile(t, fixtureFile, db, "sqlite")
+}
+
+func TestVerifyReportsDifferencesSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a test schema
+	_, err = db.Exec(testSchemaSQLite)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load the fixture
+	err = Load([]byte(testData), db, "sqlite")
+	assert.Nil(t, err)
+
+	// Change some data behind the fixture's back
+	_, err = db.Exec(`UPDATE some_table SET string_field = 'bazqux', boolean_field = 0`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = db.Exec(`DELETE FROM join_table`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Verification should fail with a per-row, per-column diff
+	err = Verify([]byte(testData), db, "sqlite")
+	if assert.IsType(t, new(VerificationError), err) {
+		diffs := err.(*VerificationError).Diffs
+		assert.Equal(t, 2, len(diffs))
+		assert.Equal(t, "some_table", diffs[0].Table)
+		assert.Equal(t, 2, len(diffs[0].Columns))
+		assert.Equal(t, "join_table", diffs[1].Table)
+		assert.True(t, diffs[1].Missing)
+	}
+	assert.EqualError(t, err, `Database does not match fixture (2 rows differ):
+  row 1, some_table (id=1):
+    boolean_field: expected true, got 0
+    string_field: expected "foobar", got "bazqux"
+  row 3, join_table (other_id=2, some_id=1): row not found`)
+}
+
+func TestValuesEqual(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	utc := time.Date(2016, 6, 3, 10, 0, 0, 0, time.UTC)
+
+	assert.True(t, valuesEqual(1, int64(1)))
+	assert.True(t, valuesEqual(1.0, int64(1)))
+	assert.True(t, valuesEqual("foobar", []byte("foobar")))
+	assert.True(t, valuesEqual(true, int64(1)))
+	assert.True(t, valuesEqual(false, int64(0)))
+	assert.True(t, valuesEqual(123, []byte("123")))
+	assert.True(t, valuesEqual(utc, utc.In(loc)))
+	assert.True(t, valuesEqual("2016-06-03T12:00:00+02:00", utc))
+	assert.True(t, valuesEqual("2016-06-03 10:00:00", utc))
+	assert.True(t, valuesEqual(nil, nil))
+
+	assert.False(t, valuesEqual(1, int64(2)))
+	assert.False(t, valuesEqual(true, int64(0)))
+	assert.False(t, valuesEqual("foobar", nil))
+	assert.False(t, valuesEqual(nil, "foobar"))
+	assert.False(t, valuesEqual("2016-06-03 11:00:00", utc))
+}
+
+func TestVerifyStrictReportsExtraRowsSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a test schema
+	_, err = db.Exec(testSchemaSQLite)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load the fixture and add rows it does not know about
+	err = Load([]byte(testData), db, "sqlite")
+	assert.Nil(t, err)
+	_, err = db.Exec(`INSERT INTO some_table(id, string_field, boolean_field) VALUES(3, 'extra', 0)`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO join_table(some_id, other_id) VALUES(3, 2)`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Non strict verification ignores extra rows
+	err = Verify([]byte(testData), db, "sqlite")
+	assert.Nil(t, err)
+
+	// Strict verification reports them
+	err = Verify([]byte(testData), db, "sqlite", Strict())
+	assert.EqualError(t, err, `Database does not match fixture (2 rows differ):
+  unexpected row, some_table (id=3)
+  unexpected row, join_table (other_id=2, some_id=3)`)
+}
+
+func TestVerifyChecksTimestampsSQLite(t *testing.T) {
+	// Delete the test database
+	os.Remove(testSQLiteDb)
+
+	var (
+		db  *sql.DB
+		err error
+	)
+
+	// Connect to an in-memory SQLite database
+	db, err = sql.Open("sqlite3", testSQLiteDb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a test schema
+	_, err = db.Exec(testSchemaSQLite)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load the fixture
+	err = Load([]byte(testData), db, "sqlite")
+	assert.Nil(t, err)
+
+	// Move created_at back in time
+	_, err = db.Exec(
+		`UPDATE some_table SET created_at = ?`,
+		time.Now().Add(-time.Hour),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// ON_INSERT_NOW() is no longer within the default tolerance
+	err = Verify([]byte(testData), db, "sqlite", Strict())
+	if assert.IsType(t, new(VerificationError), err) {
+		diffs := err.(*VerificationError).Diffs
+		assert.Equal(t, 1, len(diffs))
+		assert.Equal(t, "created_at", diffs[0].Columns[0].Column)
+		assert.Equal(t, onInsertNow, diffs[0].Columns[0].Expected)
+	}
+
+	// It is within a larger tolerance
+	err = Verify([]byte(testData), db, "sqlite", Strict(), TimeTolerance(2*time.Hour))
+	assert.Nil(t, err)
+
+	// Ignored columns are not compared at all
+	err = Verify([]byte(testData), db, "sqlite", Strict(), IgnoreColumns("created_at"))
+	assert.Nil(t, err)
+	err = Verify([]byte(testData), db, "sqlite", Strict(), IgnoreColumns("some_table.created_at"))
+	assert.Nil(t, err)
+	err = Verify([]byte(testData), db, "sqlite", Strict(), IgnoreColumns("other_table.created_at"))
+	assert.NotNil(t, err)
+}